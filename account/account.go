@@ -1,21 +1,68 @@
 package account
 
-import "hermes/position"
+import (
+	"sort"
+	"time"
+
+	"hermes/position"
+)
 
 type Account struct {
-	AllocatedBalance float64              // Balance locked in positions.
+	AllocatedBalance float64              // Balance locked in positions as margin.
 	AvailableBalance float64              // Balance free to use.
 	ClosedPositions  []*position.Position // Self-explanatory.
+	Fees             float64              // Commissions paid on closed positions (USDT).
+	Funding          float64              // Funding paid (positive) or received (negative) on closed positions (USDT).
 	InitialBalance   float64              // Unchanged. Used for reference. NOTE: may want to rename to StartingCapital
 	Loses            int                  // Counter of losing trades.
-	NetPNL           float64              // Net PNL in USDT.
-	PNL              float64              // PNL in percentage.
+	Notional         float64              // Notional value (size) of the open positions (USDT).
+	NetPNL           float64              // Realized net PNL (closed positions and partial closes) in USDT.
+	PNL              float64              // Realized return on InitialBalance in percentage.
 	OpenPositions    []*position.Position // Self-explanatory.
 	Real             bool                 // Whether the account trades real capital or not.
 	TotalBalance     float64              // AllocatedBalance + AvailableBalance.
+	TradePNLs        map[int]float64      // Realized net PNL of each trade (partial closes included), by position ID.
 	Wins             int                  // Counter of winning trades.
 }
 
+// Ledger is the breakdown of an account's equity and P&L at given prices. Fees and funding are already
+// deducted from the P&Ls and are reported for reference.
+type Ledger struct {
+	Equity           float64 // TotalBalance + UnrealizedPNL (USDT).
+	Fees             float64 // Commissions paid on closed and open positions (USDT).
+	Funding          float64 // Funding paid (positive) or received (negative) on closed and open positions (USDT).
+	RealizedPNL      float64 // Net PNL of closed positions and partial closes (USDT).
+	Return           float64 // Return of Equity on InitialBalance (percentage).
+	UnrealizedPNL    float64 // Net PNL of open positions (USDT).
+	UnrealizedReturn float64 // Return of UnrealizedPNL on TotalBalance (percentage).
+}
+
+// Summary holds the performance of an account over a period.
+type Summary struct {
+	Best     *position.Position // Position closed with the highest trade PNL (nil if none closed).
+	BestPNL  float64            // Trade PNL of Best, partial closes included (USDT).
+	Closed   int                // Count of positions closed.
+	Fees     float64            // Commissions paid on the positions closed (USDT).
+	From     time.Time          // Start of the period (inclusive).
+	Funding  float64            // Funding paid (positive) or received (negative) on the positions closed (USDT).
+	Loses    int                // Count of losing trades.
+	NetPNL   float64            // Net PNL of the positions closed (USDT).
+	Opened   int                // Count of positions opened (whether closed or not).
+	To       time.Time          // End of the period (exclusive).
+	Wins     int                // Count of winning trades.
+	Worst    *position.Position // Position closed with the lowest trade PNL (nil if none closed).
+	WorstPNL float64            // Trade PNL of Worst, partial closes included (USDT).
+}
+
+// WinRate returns the percentage of winning trades (0 if none closed).
+func (s *Summary) WinRate() float64 {
+	if s.Closed == 0 {
+		return 0
+	}
+
+	return float64(s.Wins) / float64(s.Closed) * 100
+}
+
 // New creates an Account struct with all fields initialized.
 func New(initialBalance float64, real bool) Account {
 	// See github.com/golang/go/wiki/CodeReviewComments#declaring-empty-slices
@@ -28,38 +75,35 @@ func New(initialBalance float64, real bool) Account {
 		InitialBalance:   initialBalance,
 		Loses:            0,
 		NetPNL:           0.0,
+		Notional:         0.0,
 		PNL:              0.0,
 		OpenPositions:    openPositions,
 		Real:             real,
 		TotalBalance:     initialBalance,
+		TradePNLs:        make(map[int]float64),
 		Wins:             0,
 	}
 }
 
 // LogNewPosition records allocated and avaible balances and adds the passed position to OpenPositions.
 func (acct *Account) LogNewPosition(p *position.Position) {
-	acct.AllocatedBalance += p.Size
-	acct.AvailableBalance -= p.Size
+	acct.AllocatedBalance += p.Margin
+	acct.AvailableBalance -= p.Margin
+	acct.Notional += p.Size
 	acct.OpenPositions = append(acct.OpenPositions, p)
 }
 
 // LogClosedPosition records balances and PNLs, adds the position passed to ClosedPositions, and
-// removes it from OpenPositions.
+// removes it from OpenPositions. The trade is a win or a loss by its TradePNLs, partial closes included.
 func (acct *Account) LogClosedPosition(p *position.Position) {
-	acct.AllocatedBalance -= p.Size
-	acct.AvailableBalance += (p.Size + p.NetPNL)
-	acct.TotalBalance += p.NetPNL
-	acct.ClosedPositions = append(acct.ClosedPositions, p)
-	acct.NetPNL += p.NetPNL
+	acct.realize(p)
 
-	if p.NetPNL > 0 {
+	if acct.TradePNLs[p.ID] > 0 {
 		acct.Wins += 1
 	} else {
 		acct.Loses += 1
 	}
 
-	acct.PNL = ((acct.TotalBalance - acct.InitialBalance) / acct.TotalBalance) * 100
-
 	openPositions := acct.OpenPositions // Used as a shorthand.
 
 	// Find and remove the position from OpenPositions.
@@ -72,32 +116,157 @@ func (acct *Account) LogClosedPosition(p *position.Position) {
 	}
 }
 
-// CalculateOpenPositionsPNLs calculates the P&L (in USDT and percentage) for each open position.
-func (acct *Account) CalculateOpenPositionsPNLs(symbolPrices map[string]float64) map[string][]float64 {
-	pnls := make(map[string][]float64, len(acct.OpenPositions))
+// LogPartialClose records balances and PNLs of the portion returned by Position.PartialClose and adds it
+// to ClosedPositions. The position it was split off stays in OpenPositions and wins/losses are left
+// unchanged until it is closed.
+func (acct *Account) LogPartialClose(part *position.Position) {
+	acct.realize(part)
+}
+
+// Snapshot returns a copy of the account that is safe to read while the account keeps changing: its open
+// positions are copied, and so are its slices and maps.
+func (acct *Account) Snapshot() Account {
+	snapshot := *acct
+	snapshot.ClosedPositions = append([]*position.Position(nil), acct.ClosedPositions...)
+	snapshot.OpenPositions = make([]*position.Position, len(acct.OpenPositions))
+	snapshot.TradePNLs = make(map[int]float64, len(acct.TradePNLs))
+
+	for i, p := range acct.OpenPositions {
+		copied := *p
+		snapshot.OpenPositions[i] = &copied
+	}
 
+	for id, pnl := range acct.TradePNLs {
+		snapshot.TradePNLs[id] = pnl
+	}
+
+	return snapshot
+}
+
+// FindOpenPosition returns the open position with the ID passed, or nil if there is none.
+func (acct *Account) FindOpenPosition(id int) *position.Position {
 	for _, p := range acct.OpenPositions {
-		pnl := p.CalculatePNL(symbolPrices[p.Symbol])
+		if p.ID == id {
+			return p
+		}
+	}
+
+	return nil
+}
 
-		pnls[p.Symbol] = append(pnls[p.Symbol], pnl*p.Size, pnl*100)
+// OpenPositionsOf returns the open positions of symbol, oldest first.
+func (acct *Account) OpenPositionsOf(symbol string) []*position.Position {
+	var openPositions []*position.Position
+
+	for _, p := range acct.OpenPositions {
+		if p.Symbol == symbol {
+			openPositions = append(openPositions, p)
+		}
 	}
 
-	return pnls
+	sort.Slice(openPositions, func(i, j int) bool { return openPositions[i].ID < openPositions[j].ID })
+
+	return openPositions
 }
 
-// CalculateUnrealizedPNL calculates the total unrealized P&L of all open positions, returning
-// the USDT and percentage values.
+// CalculateUnrealizedPNL calculates the total unrealized P&L of all open positions (after the fees and
+// funding paid so far), returning the USDT value and its return on TotalBalance (percentage).
 func (acct *Account) CalculateUnrealizedPNL(symbolPrices map[string]float64) (float64, float64) {
-	unrealizedPNL := 0.0
+	ledger := acct.Ledger(symbolPrices)
+
+	return ledger.UnrealizedPNL, ledger.UnrealizedReturn
+}
+
+// Ledger returns the breakdown of the account's equity and P&L at the prices passed.
+func (acct *Account) Ledger(symbolPrices map[string]float64) Ledger {
+	ledger := Ledger{Fees: acct.Fees, Funding: acct.Funding, RealizedPNL: acct.NetPNL}
 
 	for _, p := range acct.OpenPositions {
-		pnl := p.CalculatePNL(symbolPrices[p.Symbol])
+		ledger.Fees += p.Fees
+		ledger.Funding += p.Funding
+		ledger.UnrealizedPNL += p.UnrealizedPNL(symbolPrices[p.Symbol])
+	}
 
-		unrealizedPNL += pnl * p.Size
+	ledger.Equity = acct.TotalBalance + ledger.UnrealizedPNL
+	ledger.Return = acct.returnOn(ledger.Equity)
+
+	if acct.TotalBalance > 0 {
+		ledger.UnrealizedReturn = ledger.UnrealizedPNL / acct.TotalBalance * 100
+	}
+
+	return ledger
+}
+
+// realize records the balances, fees, funding and PNLs of the closed position passed, and adds it to
+// ClosedPositions.
+func (acct *Account) realize(p *position.Position) {
+	acct.AllocatedBalance -= p.Margin
+	acct.AvailableBalance += (p.Margin + p.NetPNL)
+	acct.Notional -= p.Size
+	acct.TotalBalance += p.NetPNL
+	acct.ClosedPositions = append(acct.ClosedPositions, p)
+	acct.NetPNL += p.NetPNL
+	acct.Fees += p.Fees
+	acct.Funding += p.Funding
+	acct.PNL = acct.returnOn(acct.TotalBalance)
+	acct.TradePNLs[p.ID] += p.NetPNL
+}
+
+// returnOn returns the return (percentage) of balance on InitialBalance.
+func (acct *Account) returnOn(balance float64) float64 {
+	return (balance - acct.InitialBalance) / acct.InitialBalance * 100
+}
+
+// Summarize returns the performance of the account over the period [from, to).
+func (acct *Account) Summarize(from time.Time, to time.Time) Summary {
+	s := Summary{From: from, To: to}
+
+	inPeriod := func(t time.Time) bool {
+		return !t.Before(from) && t.Before(to)
+	}
+
+	for _, p := range acct.OpenPositions {
+		if inPeriod(p.EntryTime) {
+			s.Opened += 1
+		}
 	}
 
-	liveBalance := acct.TotalBalance + unrealizedPNL
-	rawPNL := ((liveBalance - acct.InitialBalance) / liveBalance) * 100
+	for _, p := range acct.ClosedPositions {
+		if inPeriod(p.EntryTime) && !p.Partial {
+			s.Opened += 1
+		}
+
+		if !inPeriod(p.ExitTime) {
+			continue
+		}
+
+		s.NetPNL += p.NetPNL
+		s.Fees += p.Fees
+		s.Funding += p.Funding
+
+		// Partial closes count towards the PNL only: their position is counted when fully closed.
+		if p.Partial {
+			continue
+		}
+
+		s.Closed += 1
+
+		tradePNL := acct.TradePNLs[p.ID]
+
+		if tradePNL > 0 {
+			s.Wins += 1
+		} else {
+			s.Loses += 1
+		}
+
+		if s.Best == nil || tradePNL > s.BestPNL {
+			s.Best, s.BestPNL = p, tradePNL
+		}
+
+		if s.Worst == nil || tradePNL < s.WorstPNL {
+			s.Worst, s.WorstPNL = p, tradePNL
+		}
+	}
 
-	return unrealizedPNL, rawPNL
+	return s
 }
@@ -0,0 +1,310 @@
+package account
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"hermes/analysis"
+	"hermes/position"
+)
+
+const TAKER = 0.0005 // Taker fee rate of the tests.
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// openTestPosition logs a new position of quantity on side entered at 100 at entryTime with 1x leverage.
+func openTestPosition(acct *Account, id int, side string, quantity float64, entryTime time.Time) *position.Position {
+	p := &position.Position{
+		Asset:           &analysis.Asset{PricePrecision: 2, QuantityPrecision: 3, StepSize: 0.001},
+		EntryPrice:      100,
+		EntryTime:       entryTime,
+		ID:              id,
+		InitialQuantity: quantity,
+		Leverage:        1,
+		Margin:          100 * quantity,
+		MarginType:      position.ISOLATED,
+		Quantity:        quantity,
+		Side:            side,
+		Size:            100 * quantity,
+		Symbol:          "BTCUSDT",
+	}
+
+	p.ChargeFee(p.EntryPrice, TAKER)
+	acct.LogNewPosition(p)
+
+	return p
+}
+
+func almostEqual(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestLogClosedPosition(t *testing.T) {
+	tests := []struct {
+		name      string
+		side      string
+		exitPrice float64
+		wantWins  int
+	}{
+		{"long win", analysis.BUY, 110, 1},
+		{"long loss", analysis.BUY, 90, 0},
+		{"short win", analysis.SELL, 90, 1},
+		{"short loss", analysis.SELL, 110, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acct := New(1000, false)
+			p := openTestPosition(&acct, 1, tt.side, 1, start)
+
+			if acct.AllocatedBalance != 100 || acct.AvailableBalance != 900 || acct.Notional != 100 {
+				t.Fatalf("balances after opening = %g allocated, %g available, %g notional, want 100, 900, 100",
+					acct.AllocatedBalance, acct.AvailableBalance, acct.Notional)
+			}
+
+			p.Close(tt.exitPrice, "TP", start.Add(time.Hour), TAKER)
+			acct.LogClosedPosition(p)
+
+			if acct.Wins != tt.wantWins || acct.Loses != 1-tt.wantWins {
+				t.Errorf("Wins, Loses = %d, %d, want %d, %d", acct.Wins, acct.Loses, tt.wantWins, 1-tt.wantWins)
+			}
+
+			if len(acct.OpenPositions) != 0 || len(acct.ClosedPositions) != 1 {
+				t.Errorf("positions = %d open, %d closed, want 0, 1", len(acct.OpenPositions), len(acct.ClosedPositions))
+			}
+
+			if acct.AllocatedBalance != 0 || acct.Notional != 0 {
+				t.Errorf("AllocatedBalance, Notional = %g, %g, want 0, 0", acct.AllocatedBalance, acct.Notional)
+			}
+
+			wantBalance := 1000 + p.NetPNL
+			if !almostEqual(acct.TotalBalance, wantBalance) || !almostEqual(acct.AvailableBalance, wantBalance) {
+				t.Errorf("TotalBalance, AvailableBalance = %g, %g, want %g", acct.TotalBalance, acct.AvailableBalance, wantBalance)
+			}
+
+			if !almostEqual(acct.NetPNL, p.NetPNL) || !almostEqual(acct.PNL, p.NetPNL/1000*100) {
+				t.Errorf("NetPNL, PNL = %g, %g, want %g, %g", acct.NetPNL, acct.PNL, p.NetPNL, p.NetPNL/1000*100)
+			}
+
+			if !almostEqual(acct.Fees, p.Fees) {
+				t.Errorf("Fees = %g, want %g", acct.Fees, p.Fees)
+			}
+		})
+	}
+}
+
+func TestLogPartialClose(t *testing.T) {
+	tests := []struct {
+		name      string
+		side      string
+		exitPrice float64
+	}{
+		{"long win", analysis.BUY, 110},
+		{"long loss", analysis.BUY, 90},
+		{"short win", analysis.SELL, 90},
+		{"short loss", analysis.SELL, 110},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acct := New(1000, false)
+			p := openTestPosition(&acct, 1, tt.side, 2, start)
+
+			part := p.PartialClose(0.5, tt.exitPrice, "TP1", start.Add(time.Hour), TAKER)
+			acct.LogPartialClose(part)
+
+			if acct.Wins != 0 || acct.Loses != 0 {
+				t.Errorf("Wins, Loses = %d, %d, want 0, 0", acct.Wins, acct.Loses)
+			}
+
+			if len(acct.OpenPositions) != 1 || len(acct.ClosedPositions) != 1 {
+				t.Errorf("positions = %d open, %d closed, want 1, 1", len(acct.OpenPositions), len(acct.ClosedPositions))
+			}
+
+			if !almostEqual(acct.AllocatedBalance, 100) || !almostEqual(acct.Notional, 100) {
+				t.Errorf("AllocatedBalance, Notional = %g, %g, want 100, 100", acct.AllocatedBalance, acct.Notional)
+			}
+
+			if !almostEqual(acct.TotalBalance, 1000+part.NetPNL) || !almostEqual(acct.AvailableBalance, 900+part.NetPNL) {
+				t.Errorf("TotalBalance, AvailableBalance = %g, %g, want %g, %g",
+					acct.TotalBalance, acct.AvailableBalance, 1000+part.NetPNL, 900+part.NetPNL)
+			}
+
+			// Closing the rest frees the whole margin.
+			p.Close(tt.exitPrice, "TP", start.Add(2*time.Hour), TAKER)
+			acct.LogClosedPosition(p)
+
+			wantBalance := 1000 + part.NetPNL + p.NetPNL
+			if acct.AllocatedBalance != 0 || !almostEqual(acct.AvailableBalance, wantBalance) {
+				t.Errorf("AllocatedBalance, AvailableBalance = %g, %g, want 0, %g", acct.AllocatedBalance, acct.AvailableBalance, wantBalance)
+			}
+
+			if acct.Wins+acct.Loses != 1 {
+				t.Errorf("Wins + Loses = %d, want 1", acct.Wins+acct.Loses)
+			}
+		})
+	}
+}
+
+func TestScaledOutTrade(t *testing.T) {
+	tests := []struct {
+		name      string
+		side      string
+		tps       []float64 // Exit prices of the 50% and 30% take-profits.
+		sl        float64   // Exit price of the last 20%.
+		wantWins  int
+		wantWorst int // ID of the worst trade, against a small loss (#2).
+	}{
+		{"long stopped after take-profits", analysis.BUY, []float64{110, 120}, 96, 1, 2},
+		{"short stopped after take-profits", analysis.SELL, []float64{90, 80}, 104, 1, 2},
+		{"long stopped after a small take-profit", analysis.BUY, []float64{100.5, 101}, 80, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acct := New(1000, false)
+			p := openTestPosition(&acct, 1, tt.side, 10, start)
+
+			tradePNL := 0.0
+			for i, fraction := range []float64{0.5, 0.6} { // 50% of 10, then 30% of 10 (60% of the 5 left).
+				part := p.PartialClose(fraction, tt.tps[i], "TP", start.Add(time.Hour), TAKER)
+				acct.LogPartialClose(part)
+				tradePNL += part.NetPNL
+			}
+
+			p.Close(tt.sl, "SL", start.Add(2*time.Hour), TAKER)
+			acct.LogClosedPosition(p)
+			tradePNL += p.NetPNL
+
+			if p.NetPNL >= 0 {
+				t.Fatalf("rest NetPNL = %g, want a loss", p.NetPNL)
+			}
+
+			if !almostEqual(acct.TradePNLs[p.ID], tradePNL) {
+				t.Errorf("TradePNLs[%d] = %g, want %g", p.ID, acct.TradePNLs[p.ID], tradePNL)
+			}
+
+			if acct.Wins != tt.wantWins || acct.Loses != 1-tt.wantWins {
+				t.Errorf("Wins, Loses = %d, %d, want %d, %d", acct.Wins, acct.Loses, tt.wantWins, 1-tt.wantWins)
+			}
+
+			// Trades are ranked by their trade PNL, not by the NetPNL of their last part.
+			smallLoss := openTestPosition(&acct, 2, tt.side, 1, start)
+			smallLoss.Close(100, "SL", start.Add(2*time.Hour), TAKER)
+			acct.LogClosedPosition(smallLoss)
+
+			s := acct.Summarize(start, start.Add(24*time.Hour))
+			if s.Wins != tt.wantWins || s.Closed != 2 {
+				t.Errorf("Summarize: Wins, Closed = %d, %d, want %d, 2", s.Wins, s.Closed, tt.wantWins)
+			}
+
+			if s.Worst.ID != tt.wantWorst || s.WorstPNL != acct.TradePNLs[tt.wantWorst] {
+				t.Errorf("Summarize: Worst = #%d ($%g), want #%d", s.Worst.ID, s.WorstPNL, tt.wantWorst)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	acct := New(1000, false)
+	day := 24 * time.Hour
+
+	// Opened and closed in the period: a win, a loss, and a win with a partial close.
+	win := openTestPosition(&acct, 1, analysis.BUY, 1, start)
+	win.Close(110, "TP", start.Add(time.Hour), TAKER)
+	acct.LogClosedPosition(win)
+
+	loss := openTestPosition(&acct, 2, analysis.SELL, 1, start)
+	loss.Close(110, "SL", start.Add(time.Hour), TAKER)
+	acct.LogClosedPosition(loss)
+
+	scaledOut := openTestPosition(&acct, 3, analysis.BUY, 2, start)
+	part := scaledOut.PartialClose(0.5, 105, "TP1", start.Add(time.Hour), TAKER)
+	acct.LogPartialClose(part)
+	scaledOut.Close(120, "TP", start.Add(2*time.Hour), TAKER)
+	acct.LogClosedPosition(scaledOut)
+
+	// Opened in the period, still open.
+	openTestPosition(&acct, 4, analysis.BUY, 1, start.Add(time.Hour))
+
+	// Opened before the period, closed in it.
+	old := openTestPosition(&acct, 5, analysis.SELL, 1, start.Add(-day))
+	old.Close(90, "TP", start.Add(time.Hour), TAKER)
+	acct.LogClosedPosition(old)
+
+	// Closed after the period.
+	late := openTestPosition(&acct, 6, analysis.BUY, 1, start)
+	late.Close(90, "SL", start.Add(day), TAKER)
+	acct.LogClosedPosition(late)
+
+	s := acct.Summarize(start, start.Add(day))
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"Opened", s.Opened, 5},
+		{"Closed", s.Closed, 4},
+		{"Wins", s.Wins, 3},
+		{"Loses", s.Loses, 1},
+		{"Best", s.Best, scaledOut},
+		{"BestPNL", s.BestPNL, part.NetPNL + scaledOut.NetPNL},
+		{"Worst", s.Worst, loss},
+		{"WorstPNL", s.WorstPNL, loss.NetPNL},
+		{"WinRate", s.WinRate(), 75.0},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	wantNetPNL := win.NetPNL + loss.NetPNL + part.NetPNL + scaledOut.NetPNL + old.NetPNL
+	if !almostEqual(s.NetPNL, wantNetPNL) {
+		t.Errorf("NetPNL = %g, want %g", s.NetPNL, wantNetPNL)
+	}
+
+	wantFees := win.Fees + loss.Fees + part.Fees + scaledOut.Fees + old.Fees
+	if !almostEqual(s.Fees, wantFees) {
+		t.Errorf("Fees = %g, want %g", s.Fees, wantFees)
+	}
+}
+
+func TestCalculateUnrealizedPNL(t *testing.T) {
+	acct := New(1000, false)
+
+	// A realized win, which is not part of the unrealized return.
+	win := openTestPosition(&acct, 1, analysis.BUY, 1, start)
+	win.Close(150, "TP", start.Add(time.Hour), TAKER)
+	acct.LogClosedPosition(win)
+
+	long := openTestPosition(&acct, 2, analysis.BUY, 1, start)
+	short := openTestPosition(&acct, 3, analysis.SELL, 1, start)
+
+	tests := []struct {
+		name  string
+		price float64
+	}{
+		{"up", 110},
+		{"down", 80},
+		{"flat", 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unrealizedPNL, unrealizedReturn := acct.CalculateUnrealizedPNL(map[string]float64{"BTCUSDT": tt.price})
+
+			wantPNL := long.UnrealizedPNL(tt.price) + short.UnrealizedPNL(tt.price)
+			if !almostEqual(unrealizedPNL, wantPNL) {
+				t.Errorf("unrealized PNL = %g, want %g", unrealizedPNL, wantPNL)
+			}
+
+			if wantReturn := wantPNL / acct.TotalBalance * 100; !almostEqual(unrealizedReturn, wantReturn) {
+				t.Errorf("unrealized return = %g%%, want %g%%", unrealizedReturn, wantReturn)
+			}
+		})
+	}
+}
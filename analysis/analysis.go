@@ -1,6 +1,7 @@
 package analysis
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/markcheno/go-talib"
@@ -18,11 +19,110 @@ type Alert struct {
 // Asset defines the characteristics of an exchange's asset.
 type Asset struct {
 	BaseAsset         string  // Base of the asset (e.g., "BTC", "ETH")
-	MaxQuantity       float64 // Maximum quantity allowed to trade.
-	MinQuantity       float64 // Minimum quantity allowed to trade.
+	MarketMaxQuantity float64 // Maximum quantity allowed in market orders (MARKET_LOT_SIZE).
+	MarketMinQuantity float64 // Minimum quantity allowed in market orders (MARKET_LOT_SIZE).
+	MarketStepSize    float64 // Quantity increments allowed in market orders (MARKET_LOT_SIZE).
+	MaxPrice          float64 // Maximum price allowed (PRICE_FILTER).
+	MaxQuantity       float64 // Maximum quantity allowed to trade (LOT_SIZE).
+	MinNotional       float64 // Minimum notional value (price * quantity) allowed to trade (MIN_NOTIONAL).
+	MinPrice          float64 // Minimum price allowed (PRICE_FILTER).
+	MinQuantity       float64 // Minimum quantity allowed to trade (LOT_SIZE).
+	MultiplierDown    float64 // Lowest price allowed, as a multiplier of the mark price (PERCENT_PRICE).
+	MultiplierUp      float64 // Highest price allowed, as a multiplier of the mark price (PERCENT_PRICE).
 	PricePrecision    int     // Maximum number of decimals allowed in the order's price.
 	QuantityPrecision int     // Maximum number of decimals allowed in the order's quantity.
+	StepSize          float64 // Quantity increments allowed (LOT_SIZE).
 	Symbol            string  // Representation of the asset. "<BASE><QUOTE>"
+	TickSize          float64 // Price increments allowed (PRICE_FILTER).
+}
+
+// NormalizeQuantity caps quantity at the maximum of orderType (LIMIT_ORDER, MARKET_ORDER) and rounds it
+// down to its step size. Filters left at 0 (e.g., unknown) are ignored.
+func (asset *Asset) NormalizeQuantity(quantity float64, orderType string) float64 {
+	_, maxQuantity, stepSize := asset.lotSize(orderType)
+
+	if maxQuantity > 0 && quantity > maxQuantity {
+		quantity = maxQuantity
+	}
+
+	if stepSize > 0 {
+		// Nudge by an epsilon so that quantities already on a step are not floored to the previous one.
+		quantity = math.Floor(quantity/stepSize+1e-9) * stepSize
+	}
+
+	return Round(quantity, asset.QuantityPrecision)
+}
+
+// lotSize returns the minimum, maximum, and step size of the quantities of orderType: LOT_SIZE for limit
+// orders, MARKET_LOT_SIZE for market orders (LOT_SIZE's maximum and step size when left at 0).
+func (asset *Asset) lotSize(orderType string) (float64, float64, float64) {
+	if orderType == LIMIT_ORDER {
+		return asset.MinQuantity, asset.MaxQuantity, asset.StepSize
+	}
+
+	maxQuantity, stepSize := asset.MarketMaxQuantity, asset.MarketStepSize
+	if maxQuantity == 0 {
+		maxQuantity = asset.MaxQuantity
+	}
+
+	if stepSize == 0 {
+		stepSize = asset.StepSize
+	}
+
+	return math.Max(asset.MinQuantity, asset.MarketMinQuantity), maxQuantity, stepSize
+}
+
+// NormalizePrice rounds price to the nearest tick. Filters left at 0 (e.g., unknown) are ignored.
+func (asset *Asset) NormalizePrice(price float64) float64 {
+	if asset.TickSize > 0 {
+		price = math.Round(price/asset.TickSize) * asset.TickSize
+	}
+
+	return Round(price, asset.PricePrecision)
+}
+
+// ValidateOrder returns an error if an order of orderType (LIMIT_ORDER, MARKET_ORDER) of quantity at price
+// breaks the asset's lot size or minimum notional filters.
+func (asset *Asset) ValidateOrder(quantity float64, price float64, orderType string) error {
+	minQuantity, maxQuantity, _ := asset.lotSize(orderType)
+
+	switch {
+	case quantity < minQuantity:
+		return fmt.Errorf("quantity %g below the minimum of %g", quantity, minQuantity)
+	case maxQuantity > 0 && quantity > maxQuantity:
+		return fmt.Errorf("quantity %g above the maximum of %g", quantity, maxQuantity)
+	case quantity*price < asset.MinNotional:
+		return fmt.Errorf("notional $%.2f below the minimum of $%g", quantity*price, asset.MinNotional)
+	}
+
+	return nil
+}
+
+// ValidatePrice returns an error if price breaks the asset's price filter, or its percent price filter
+// relative to markPrice (skipped when 0, e.g., for SL/TP enforced by the engine rather than sent).
+func (asset *Asset) ValidatePrice(price float64, markPrice float64) error {
+	switch {
+	case asset.MinPrice > 0 && price < asset.MinPrice:
+		return fmt.Errorf("price %g below the minimum of %g", price, asset.MinPrice)
+	case asset.MaxPrice > 0 && price > asset.MaxPrice:
+		return fmt.Errorf("price %g above the maximum of %g", price, asset.MaxPrice)
+	case markPrice == 0:
+		return nil
+	case asset.MultiplierDown > 0 && price < markPrice*asset.MultiplierDown:
+		return fmt.Errorf("price %g below %g%% of the mark price", price, asset.MultiplierDown*100)
+	case asset.MultiplierUp > 0 && price > markPrice*asset.MultiplierUp:
+		return fmt.Errorf("price %g above %g%% of the mark price", price, asset.MultiplierUp*100)
+	}
+
+	return nil
+}
+
+// Candle holds the OHLC prices of a kline.
+type Candle struct {
+	Open  float64
+	High  float64
+	Low   float64
+	Close float64
 }
 
 type Analysis struct {
@@ -42,6 +142,17 @@ type Analysis struct {
 	Trend       string    // Based on EMA_050, EMA_200, and Price.
 }
 
+// Indicators is a snapshot of the indicators of an analysis (e.g., at a position's entry).
+type Indicators struct {
+	EMA_050   float64 `json:"ema050"`
+	EMA_100   float64 `json:"ema100"`
+	EMA_200   float64 `json:"ema200"`
+	EMACross  string  `json:"emaCross"`
+	RSI       float64 `json:"rsi"`
+	RSISignal string  `json:"rsiSignal"`
+	Trend     string  `json:"trend"`
+}
+
 // Value for neutral signal (EMACross, RSISignal, and Trend).
 const NA = "NA"
 
@@ -80,6 +191,12 @@ const (
 	SELL = "SELL"
 )
 
+// Order types, whose quantities are governed by different lot size filters.
+const (
+	LIMIT_ORDER  = "LIMIT"  // LOT_SIZE.
+	MARKET_ORDER = "MARKET" // MARKET_LOT_SIZE (falling back to LOT_SIZE for the filters left at 0).
+)
+
 // NOTE: may want to move to telegram.go, since that is where this map is used.
 var Emojis = map[string]string{
 	BUY:           "🚀",
@@ -128,6 +245,35 @@ func New(asset *Asset, closes []float64, lastIndex int) Analysis {
 	return a
 }
 
+// Indicators returns a snapshot of the analysis' indicators.
+func (a *Analysis) Indicators() Indicators {
+	return Indicators{
+		EMA_050:   a.EMA_050,
+		EMA_100:   a.EMA_100,
+		EMA_200:   a.EMA_200,
+		EMACross:  a.EMACross,
+		RSI:       a.RSI,
+		RSISignal: a.RSISignal,
+		Trend:     a.Trend,
+	}
+}
+
+// ATR returns the latest Average True Range of the candles over period (0 if there are not enough candles).
+func ATR(candles []Candle, period int) float64 {
+	if len(candles) <= period {
+		return 0
+	}
+
+	highs, lows, closes := make([]float64, len(candles)), make([]float64, len(candles)), make([]float64, len(candles))
+	for i, candle := range candles {
+		highs[i], lows[i], closes[i] = candle.High, candle.Low, candle.Close
+	}
+
+	atr := talib.Atr(highs, lows, closes, period)
+
+	return atr[len(atr)-1]
+}
+
 // TriggersAlert...
 func (a *Analysis) TriggersAlert(alerts *[]Alert) (bool, float64) {
 	price := a.Price
@@ -238,3 +384,10 @@ func (a *Analysis) evaluateRSI() string {
 
 	return NA
 }
+
+// Round rounds value to decimals.
+func Round(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+
+	return math.Round(value*factor) / factor
+}
@@ -0,0 +1,225 @@
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+
+	"hermes/analysis"
+	"hermes/position"
+
+	"github.com/markcheno/go-talib"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+const CANDLES = 100               // Number of (latest) candles drawn.
+const WIDTH = 24 * vg.Centimeter  // Width of the image.
+const HEIGHT = 16 * vg.Centimeter // Height of the image.
+const PRICE_PANE = 0.72           // Share of the height taken by the price pane (the rest is RSI's).
+
+var (
+	bearishColor = color.RGBA{R: 239, G: 83, B: 80, A: 255}
+	bullishColor = color.RGBA{R: 38, G: 166, B: 154, A: 255}
+	ema050Color  = color.RGBA{R: 255, G: 152, B: 0, A: 255}
+	ema200Color  = color.RGBA{R: 33, G: 150, B: 243, A: 255}
+	entryColor   = color.RGBA{R: 96, G: 125, B: 139, A: 255}
+	rsiColor     = color.RGBA{R: 126, G: 87, B: 194, A: 255}
+)
+
+// Render returns a PNG candlestick chart of the last CANDLES candles with EMA 50/200 overlays and
+// an RSI pane. When p is not nil, its entry, SL, and TP levels are drawn as well.
+func Render(symbol string, interval string, candles []analysis.Candle, p *position.Position) ([]byte, error) {
+	if len(candles) < CANDLES {
+		return nil, fmt.Errorf("got %d candles, need at least %d", len(candles), CANDLES)
+	}
+
+	closes := make([]float64, len(candles))
+	for i, candle := range candles {
+		closes[i] = candle.Close
+	}
+
+	first := len(candles) - CANDLES // Index of the first candle drawn.
+
+	pricePlot, err := buildPricePlot(symbol, interval, candles[first:], closes, first, p)
+	if err != nil {
+		return nil, err
+	}
+
+	rsiPlot, err := buildRSIPlot(closes, first)
+	if err != nil {
+		return nil, err
+	}
+
+	img := vgimg.New(WIDTH, HEIGHT)
+	dc := draw.New(img)
+	dc.SetColor(color.White)
+	dc.Fill(dc.Rectangle.Path())
+
+	pricePlot.Draw(draw.Crop(dc, 0, 0, HEIGHT*(1-PRICE_PANE), 0))
+	rsiPlot.Draw(draw.Crop(dc, 0, 0, 0, -HEIGHT*PRICE_PANE))
+
+	var buf bytes.Buffer
+	if _, err := (vgimg.PngCanvas{Canvas: img}).WriteTo(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// buildPricePlot returns the candlesticks, EMA 50/200, and the position's levels (if any).
+func buildPricePlot(
+	symbol string, interval string, candles []analysis.Candle, closes []float64, first int, p *position.Position,
+) (*plot.Plot, error) {
+	plt := plot.New()
+	plt.Title.Text = fmt.Sprintf("%s (%s)", symbol, interval)
+	plt.X.Tick.Marker = plot.TickerFunc(func(min, max float64) []plot.Tick { return nil })
+	plt.Legend.Top, plt.Legend.Left = true, true
+	plt.Add(plotter.NewGrid(), candlesticks(candles))
+
+	for _, ema := range []struct {
+		color  color.Color
+		period int
+	}{{ema050Color, 50}, {ema200Color, 200}} {
+		values := talib.Ema(closes, ema.period)
+
+		// With LIMIT candles, EMA 200 has a single value: draw it as a level.
+		line := buildLevel(values[len(values)-1], len(candles))
+		if len(values)-ema.period >= 1 {
+			var err error
+			if line, err = buildLine(values, first, ema.period-1); err != nil {
+				return nil, err
+			}
+		}
+
+		line.Color = ema.color
+		plt.Add(line)
+		plt.Legend.Add(fmt.Sprintf("EMA %d", ema.period), line)
+	}
+
+	if p != nil {
+		for _, level := range []struct {
+			color color.Color
+			name  string
+			price float64
+		}{{entryColor, "Entry", p.EntryPrice}, {bearishColor, "SL", p.SL}, {bullishColor, "TP", p.TP}} {
+			line := buildLevel(level.price, len(candles))
+			line.Color = level.color
+			plt.Add(line)
+			plt.Legend.Add(fmt.Sprintf("%s %g", level.name, level.price), line)
+		}
+	}
+
+	return plt, nil
+}
+
+// buildRSIPlot returns the RSI 14 with its overbought and oversold levels.
+func buildRSIPlot(closes []float64, first int) (*plot.Plot, error) {
+	plt := plot.New()
+	plt.Y.Label.Text = "RSI"
+	plt.Y.Min, plt.Y.Max = 0, 100
+	plt.X.Tick.Marker = plot.TickerFunc(func(min, max float64) []plot.Tick { return nil })
+	plt.Add(plotter.NewGrid())
+
+	line, err := buildLine(talib.Rsi(closes, 14), first, 14)
+	if err != nil {
+		return nil, err
+	}
+
+	line.Color = rsiColor
+	plt.Add(line)
+
+	for _, level := range []float64{analysis.RSI_HOT_L1, analysis.RSI_COLD_L1} {
+		levelLine := buildLevel(level, len(closes)-first)
+		levelLine.Color = entryColor
+		plt.Add(levelLine)
+	}
+
+	return plt, nil
+}
+
+// buildLine returns the values from index first as a line, skipping the ones before valid (i.e.,
+// not computed yet by the indicator).
+func buildLine(values []float64, first int, valid int) (*plotter.Line, error) {
+	var xys plotter.XYs
+
+	for i := first; i < len(values); i++ {
+		if i >= valid {
+			xys = append(xys, plotter.XY{X: float64(i - first), Y: values[i]})
+		}
+	}
+
+	line, err := plotter.NewLine(xys)
+	if err != nil {
+		return nil, err
+	}
+
+	line.Width = vg.Points(1.5)
+
+	return line, nil
+}
+
+// buildLevel returns a dashed horizontal line at value across count candles.
+func buildLevel(value float64, count int) *plotter.Line {
+	line := &plotter.Line{XYs: plotter.XYs{{X: 0, Y: value}, {X: float64(count - 1), Y: value}}}
+	line.Width = vg.Points(1)
+	line.Dashes = []vg.Length{vg.Points(4), vg.Points(4)}
+
+	return line
+}
+
+// candlesticks is a plot.Plotter drawing candles at X = 0, 1, ...
+type candlesticks []analysis.Candle
+
+func (c candlesticks) Plot(canvas draw.Canvas, plt *plot.Plot) {
+	trX, trY := plt.Transforms(&canvas)
+	bodyWidth := (trX(1) - trX(0)) * 0.6
+
+	for i, candle := range c {
+		fill := bullishColor
+		if candle.Close < candle.Open {
+			fill = bearishColor
+		}
+
+		x := trX(float64(i))
+
+		canvas.StrokeLine2(draw.LineStyle{Color: fill, Width: vg.Points(1)}, x, trY(candle.Low), x, trY(candle.High))
+
+		top, bottom := trY(candle.Open), trY(candle.Close)
+		if bottom > top {
+			top, bottom = bottom, top
+		}
+
+		if top-bottom < vg.Points(1) { // Keep dojis visible.
+			top += vg.Points(0.5)
+			bottom -= vg.Points(0.5)
+		}
+
+		canvas.FillPolygon(fill, []vg.Point{
+			{X: x - bodyWidth/2, Y: bottom},
+			{X: x + bodyWidth/2, Y: bottom},
+			{X: x + bodyWidth/2, Y: top},
+			{X: x - bodyWidth/2, Y: top},
+		})
+	}
+}
+
+// DataRange implements plot.DataRanger so that the axes fit every candle.
+func (c candlesticks) DataRange() (float64, float64, float64, float64) {
+	low, high := c[0].Low, c[0].High
+
+	for _, candle := range c {
+		if candle.Low < low {
+			low = candle.Low
+		}
+
+		if candle.High > high {
+			high = candle.High
+		}
+	}
+
+	return -1, float64(len(c)), low, high
+}
@@ -0,0 +1,89 @@
+package entry
+
+import (
+	"fmt"
+	"time"
+
+	"hermes/analysis"
+	"hermes/position"
+)
+
+// Entry modes and fallbacks.
+const (
+	ABANDON = "abandon" // Fallback: give up on the trade.
+	LIMIT   = "limit"   // Mode: post-only limit orders, re-priced when not filled in time.
+	MARKET  = "market"  // Mode: market orders. Fallback: enter with a market order.
+)
+
+// Settings holds how new positions are entered.
+type Settings struct {
+	Fallback string        // ABANDON, MARKET: what to do with limit orders left unfilled after Reprices.
+	Mode     string        // LIMIT, MARKET.
+	Offset   float64       // Distance of limit prices from the market price, on the favourable side (fraction).
+	Reprices int           // Times unfilled limit orders are re-priced at the market price before falling back.
+	Timeout  time.Duration // Time to wait for a limit order to be filled before re-pricing it.
+}
+
+// Validate returns an error if the mode or fallback are unknown, or a value out of its bounds.
+func (s Settings) Validate() error {
+	switch {
+	case s.Mode != LIMIT && s.Mode != MARKET:
+		return fmt.Errorf("unknown entry mode %q", s.Mode)
+	case s.Fallback != ABANDON && s.Fallback != MARKET:
+		return fmt.Errorf("unknown entry fallback %q", s.Fallback)
+	case s.Offset < 0 || s.Offset >= 1:
+		return fmt.Errorf("limit offset should be in [0, 1), got %g", s.Offset)
+	case s.Reprices < 0:
+		return fmt.Errorf("limit re-prices should not be negative, got %d", s.Reprices)
+	case s.Mode == LIMIT && s.Timeout <= 0:
+		return fmt.Errorf("limit timeout should be positive, got %s", s.Timeout)
+	}
+
+	return nil
+}
+
+// OrderType returns the type of the orders entering positions: analysis.LIMIT_ORDER or MARKET_ORDER.
+func (s Settings) OrderType() string {
+	if s.Mode == LIMIT {
+		return analysis.LIMIT_ORDER
+	}
+
+	return analysis.MARKET_ORDER
+}
+
+// LimitPrice returns the price of a limit order on side at Offset from price (below it for BUY, above it
+// for SELL), rounded to the asset's tick size.
+func (s Settings) LimitPrice(asset *analysis.Asset, side string, price float64) float64 {
+	limit := price * (1 - s.Offset)
+	if side == analysis.SELL {
+		limit = price * (1 + s.Offset)
+	}
+
+	return asset.NormalizePrice(limit)
+}
+
+// Order is a limit order entering a position, pending to be filled.
+type Order struct {
+	Attempt   int                // Times the order was re-priced (0 for the first one).
+	CheckedAt time.Time          // Time the order was last checked on the exchange (real orders).
+	PlacedAt  time.Time          // Time the order was (re-)placed.
+	Position  *position.Position // Position opened when the order is filled.
+	Price     float64            // Limit price (USDT).
+}
+
+// Fills returns whether the order is filled by the candle passed, started at start. Orders are assumed to
+// be last in the queue: filled only when the price trades through them. The candle's low and high are
+// used when it started after the order was placed; otherwise they may have been reached before, so only
+// its close is used.
+func (o *Order) Fills(candle analysis.Candle, start time.Time) bool {
+	low, high := candle.Close, candle.Close
+	if !start.Before(o.PlacedAt) {
+		low, high = candle.Low, candle.High
+	}
+
+	if o.Position.Side == analysis.BUY {
+		return low < o.Price
+	}
+
+	return high > o.Price
+}
@@ -5,39 +5,66 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"hermes/analysis"
 	"hermes/position"
-	"hermes/telegram"
 
 	"github.com/adshao/go-binance/v2"
+	"github.com/adshao/go-binance/v2/common"
 	"github.com/adshao/go-binance/v2/futures"
 	"github.com/rs/zerolog"
 )
 
+const MAX_ATTEMPTS = 5                  // Attempts of a request failing with a transient error.
+const BACKOFF = 500 * time.Millisecond  // Wait before the first retry, doubled on every retry.
+const REQUEST_TIMEOUT = 5 * time.Second // Deadline of every attempt of a request.
+
+// ORDER_ATTEMPTS are the attempts of the order and margin requests, made right away (see retryNow) as the
+// engine is locked meanwhile. The engine retries the closing orders that failed on its own later.
+const ORDER_ATTEMPTS = 2
+
+// RETRYABLE_CODES are the Binance error codes of transient failures (unknown, disconnected, too many
+// requests, unexpected response, timeout, and server busy).
+var RETRYABLE_CODES = map[int64]bool{-1000: true, -1001: true, -1003: true, -1006: true, -1007: true, -1008: true}
+
+// Fill is the executed part of an order.
+type Fill struct {
+	Done     bool    // Whether the order is done: filled, canceled, or expired (e.g., post-only taking liquidity).
+	Price    float64 // Average price of the executed quantity (USDT).
+	Quantity float64 // Executed quantity.
+}
+
 type Exchange struct {
-	*telegram.Bot
 	*futures.Client
 	*zerolog.Logger
+	hedge bool // Whether the account is in hedge mode (i.e., orders are sent with a position side).
 }
 
-func New(bot *telegram.Bot, log *zerolog.Logger) Exchange {
+func New(log *zerolog.Logger) Exchange {
 	futuresClient := binance.NewFuturesClient(os.Getenv("BINANCE_APIKEY"), os.Getenv("BINANCE_SECRETKEY"))
 
-	return Exchange{bot, futuresClient, log}
+	return Exchange{Client: futuresClient, Logger: log}
 }
 
+// FetchAssets gets the assets, filters, and the last limit candles of every tradable symbol. Symbols
+// whose candles cannot be fetched are dropped; the candles are fetched in goroutines tracked by wg.
 func (e *Exchange) FetchAssets(
-	interval string, limit int, symbolAssets map[string]analysis.Asset, symbolCloses map[string][]float64,
-	wg *sync.WaitGroup,
-) map[string]string {
+	interval string, limit int, symbolAssets map[string]analysis.Asset, symbolCandles map[string][]analysis.Candle,
+	symbolCloses map[string][]float64, wg *sync.WaitGroup,
+) (map[string]string, error) {
 	mutex := &sync.Mutex{}
 	symbolIntervalPair := make(map[string]string)
 
-	exchangeInfo, err := e.NewExchangeInfoService().Do(context.Background())
+	var exchangeInfo *futures.ExchangeInfo
+	err := e.retry("getting exchange info", func(ctx context.Context, _ int) (err error) {
+		exchangeInfo, err = e.NewExchangeInfoService().Do(ctx)
+		return err
+	})
 	if err != nil {
-		e.Fatal().Str("err", err.Error()).Msg("Crashed getting exchange info")
+		return nil, err
 	}
 
 	// Filter unwanted symbols (non-USDT, quarterlies, indexes, unactive, and 1000BTTC)
@@ -47,19 +74,46 @@ func (e *Exchange) FetchAssets(
 			rawAsset.BaseAsset != "1000BTTC" {
 
 			symbol := rawAsset.Symbol
-			maxQuantity, _ := strconv.ParseFloat(rawAsset.LotSizeFilter().MaxQuantity, 64)
-			minQuantity, _ := strconv.ParseFloat(rawAsset.LotSizeFilter().MinQuantity, 64)
-
-			symbolAssets[symbol] = analysis.Asset{
+			asset := analysis.Asset{
 				BaseAsset:         rawAsset.BaseAsset,
-				MaxQuantity:       maxQuantity,
-				MinQuantity:       minQuantity,
 				PricePrecision:    rawAsset.PricePrecision,
 				QuantityPrecision: rawAsset.QuantityPrecision,
 				Symbol:            symbol,
 			}
 
+			// NOTE: filters missing from the exchange info are left at 0 (i.e., ignored).
+			if filter := rawAsset.LotSizeFilter(); filter != nil {
+				asset.MaxQuantity = parseFilter(filter.MaxQuantity)
+				asset.MinQuantity = parseFilter(filter.MinQuantity)
+				asset.StepSize = parseFilter(filter.StepSize)
+			}
+
+			if filter := rawAsset.MarketLotSizeFilter(); filter != nil {
+				asset.MarketMaxQuantity = parseFilter(filter.MaxQuantity)
+				asset.MarketMinQuantity = parseFilter(filter.MinQuantity)
+				asset.MarketStepSize = parseFilter(filter.StepSize)
+			}
+
+			if filter := rawAsset.MinNotionalFilter(); filter != nil {
+				asset.MinNotional = parseFilter(filter.Notional)
+			}
+
+			if filter := rawAsset.PriceFilter(); filter != nil {
+				asset.MaxPrice = parseFilter(filter.MaxPrice)
+				asset.MinPrice = parseFilter(filter.MinPrice)
+				asset.TickSize = parseFilter(filter.TickSize)
+			}
+
+			if filter := rawAsset.PercentPriceFilter(); filter != nil {
+				asset.MultiplierDown = parseFilter(filter.MultiplierDown)
+				asset.MultiplierUp = parseFilter(filter.MultiplierUp)
+			}
+
+			symbolAssets[symbol] = asset
+
+			mutex.Lock()
 			symbolIntervalPair[symbol] = interval
+			mutex.Unlock()
 
 			wg.Add(1)
 
@@ -67,93 +121,399 @@ func (e *Exchange) FetchAssets(
 			go func() {
 				defer wg.Done()
 
-				klines, err := e.NewKlinesService().
-					Symbol(symbol).Interval(interval).Limit(limit).Do(context.Background())
+				var klines []*futures.Kline
+				err := e.retry("fetching klines", func(ctx context.Context, _ int) (err error) {
+					klines, err = e.NewKlinesService().
+						Symbol(symbol).Interval(interval).Limit(limit).Do(ctx)
+					return err
+				})
 				if err != nil {
-					e.Fatal().Str("err", err.Error()).Msg("Crashed fetching klines")
+					e.Error().Str("err", err.Error()).Str("Symbol", symbol).Msg("Could not fetch klines: dropping symbol")
 				}
 
 				// Discard assets with less than LIMIT candles due to impossibility of computing EMA <LIMIT>.
 				if len(klines) == limit {
 					for i := 0; i < limit; i++ {
+						open, _ := strconv.ParseFloat(klines[i].Open, 64)
+						high, _ := strconv.ParseFloat(klines[i].High, 64)
+						low, _ := strconv.ParseFloat(klines[i].Low, 64)
+
 						if close, err := strconv.ParseFloat(klines[i].Close, 64); err == nil {
 							mutex.Lock()
+							symbolCandles[symbol] = append(symbolCandles[symbol], analysis.Candle{
+								Open: open, High: high, Low: low, Close: close,
+							})
 							symbolCloses[symbol] = append(symbolCloses[symbol], close)
 							mutex.Unlock()
 						}
 					}
 				} else {
+					mutex.Lock()
 					delete(symbolIntervalPair, symbol)
+					mutex.Unlock()
 				}
 			}()
 		}
 	}
 
-	return symbolIntervalPair
+	return symbolIntervalPair, nil
 }
 
 // FetchBalance gets the total balance from the exchange's account wallet and parses it.
-func (e *Exchange) FetchBalance() float64 {
-	res, err := e.NewGetAccountService().Do(context.Background())
+func (e *Exchange) FetchBalance() (float64, error) {
+	var res *futures.Account
+	err := e.retry("getting wallet balance", func(ctx context.Context, _ int) (err error) {
+		res, err = e.NewGetAccountService().Do(ctx)
+		return err
+	})
 	if err != nil {
-		e.Fatal().Str("err", err.Error()).Msg("Crashed getting wallet balance")
+		return 0, err
 	}
 
 	balance, _ := strconv.ParseFloat(res.TotalWalletBalance, 64)
 	availableBalance := balance - (balance * .05) // NOTE: substract 5% to give margin.
 
-	return availableBalance
+	return availableBalance, nil
+}
+
+// FetchFundingRates gets the funding rate that will be paid on the next funding time for every symbol.
+func (e *Exchange) FetchFundingRates() (map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), REQUEST_TIMEOUT)
+	defer cancel()
+
+	premiumIndexes, err := e.NewPremiumIndexService().Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fundingRates := make(map[string]float64, len(premiumIndexes))
+	for _, premiumIndex := range premiumIndexes {
+		if rate, err := strconv.ParseFloat(premiumIndex.LastFundingRate, 64); err == nil {
+			fundingRates[premiumIndex.Symbol] = rate
+		}
+	}
+
+	return fundingRates, nil
+}
+
+// SetMargin sets the leverage and margin type of symbol in the exchange.
+func (e *Exchange) SetMargin(symbol string, settings position.MarginSettings) error {
+	err := e.retryNow("changing leverage", func(ctx context.Context, _ int) error {
+		_, err := e.NewChangeLeverageService().Symbol(symbol).Leverage(settings.Leverage).Do(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return e.retryNow("changing margin type", func(ctx context.Context, _ int) error {
+		err := e.NewChangeMarginTypeService().
+			Symbol(symbol).MarginType(futures.MarginType(settings.MarginType)).Do(ctx)
+
+		// Binance answers -4046 when the margin type is already set.
+		if apiErr, ok := err.(*common.APIError); ok && apiErr.Code == -4046 {
+			return nil
+		}
+
+		return err
+	})
+}
+
+// SetHedgeMode switches the account to hedge mode (a long and a short position per symbol) when hedge
+// is true, or to one-way mode otherwise. NOTE: Binance rejects the change while there are open
+// positions or orders.
+func (e *Exchange) SetHedgeMode(hedge bool) error {
+	err := e.retry("changing position mode", func(ctx context.Context, _ int) error {
+		err := e.NewChangePositionModeService().DualSide(hedge).Do(ctx)
+
+		// Binance answers -4059 when the position mode is already set.
+		if apiErr, ok := err.(*common.APIError); ok && apiErr.Code == -4059 {
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	e.hedge = hedge
+
+	return nil
 }
 
 // NewOrder creates a market order in the exchange for the passed position.
-func (e *Exchange) NewOrder(p *position.Position) {
-	asset, quantity := p.Asset, p.Quantity
+func (e *Exchange) NewOrder(p *position.Position) error {
+	side := futures.SideTypeBuy
+	if p.Side == analysis.SELL {
+		side = futures.SideTypeSell
+	}
+
+	// NOTE: API wrapper doesn't store the executed price and quantity (may be slightly off from targets).
+	orderID, err := e.placeOrder(p, p.Quantity, 0, side, false, clientOrderID(p, "open"))
+	if err != nil {
+		return err
+	}
+
+	e.Info().Int64("OrderID", orderID).Int("ID", p.ID).Str("Symbol", p.Symbol).Msg("💳 Sent order")
 
+	return nil
+}
+
+// NewLimitOrder posts a post-only (GTX) limit order at price in the exchange for the passed position, as
+// its attempt-th limit order (0 for the first one, 1 for the first re-price, ...). NOTE: Binance expires
+// post-only orders that would take liquidity right away (see FetchLimitOrder).
+func (e *Exchange) NewLimitOrder(p *position.Position, price float64, attempt int) error {
 	side := futures.SideTypeBuy
 	if p.Side == analysis.SELL {
 		side = futures.SideTypeSell
 	}
 
-	finalQuantity := strconv.FormatFloat(quantity, 'f', asset.QuantityPrecision, 64)
+	orderID, err := e.placeOrder(p, p.Quantity, price, side, false, limitOrderID(p, attempt))
+	if err != nil {
+		return err
+	}
+
+	e.Info().
+		Int64("OrderID", orderID).
+		Int("ID", p.ID).
+		Float64("Price", price).
+		Str("Symbol", p.Symbol).
+		Msg("💳 Sent limit order")
 
-	// NOTE: API wrapper doesn't store the executed price and quantity (may be slightly off from targets).
-	order, err := e.NewCreateOrderService().
-		Symbol(asset.Symbol).Side(side).Type(futures.OrderTypeMarket).Quantity(finalQuantity).
-		Do(context.Background())
+	return nil
+}
+
+// FetchLimitOrder gets the fill of the attempt-th limit order of the passed position.
+func (e *Exchange) FetchLimitOrder(p *position.Position, attempt int) (Fill, error) {
+	var order *futures.Order
+	err := e.retryNow("getting Binance order", func(ctx context.Context, _ int) (err error) {
+		order, err = e.NewGetOrderService().
+			Symbol(p.Symbol).OrigClientOrderID(limitOrderID(p, attempt)).Do(ctx)
+		return err
+	})
 	if err != nil {
-		e.Fatal().Str("err", err.Error()).Msg("Crashed creating Binance order")
+		return Fill{}, err
 	}
 
-	e.Info().Int64("OrderID", order.OrderID).Msg("💳 Sent order")
+	fill := Fill{
+		Done:     order.Status != futures.OrderStatusTypeNew && order.Status != futures.OrderStatusTypePartiallyFilled,
+		Price:    parseFilter(order.AvgPrice),
+		Quantity: parseFilter(order.ExecutedQuantity),
+	}
+
+	return fill, nil
+}
+
+// CancelLimitOrder cancels the attempt-th limit order of the passed position and returns its fill. Orders
+// already done (e.g., filled meanwhile) are not an error.
+func (e *Exchange) CancelLimitOrder(p *position.Position, attempt int) (Fill, error) {
+	var res *futures.CancelOrderResponse
+	err := e.retryNow("canceling Binance order", func(ctx context.Context, _ int) (err error) {
+		res, err = e.NewCancelOrderService().
+			Symbol(p.Symbol).OrigClientOrderID(limitOrderID(p, attempt)).Do(ctx)
+		return err
+	})
+
+	// Binance answers -2011 when the order is not open anymore.
+	if apiErr, ok := err.(*common.APIError); ok && apiErr.Code == -2011 {
+		return e.FetchLimitOrder(p, attempt)
+	} else if err != nil {
+		return Fill{}, err
+	}
+
+	fill := Fill{Done: true, Quantity: parseFilter(res.ExecutedQuantity)}
+	if fill.Quantity > 0 {
+		fill.Price = parseFilter(res.CumQuote) / fill.Quantity
+	}
+
+	e.Info().Int64("OrderID", res.OrderID).Int("ID", p.ID).Str("Symbol", p.Symbol).Msg("💳 Canceled limit order")
+
+	return fill, nil
 }
 
 // CloseOrder closes the given position in the exchange with a market order.
-func (e *Exchange) CloseOrder(p *position.Position) {
-	asset, quantity := p.Asset, p.Quantity
+func (e *Exchange) CloseOrder(p *position.Position) error {
+	return e.reduce(p, p.Quantity, "close")
+}
+
+// PartialCloseOrder closes quantity of the given position in the exchange with a market order, for its
+// take-profit level passed (1 for the first one).
+func (e *Exchange) PartialCloseOrder(p *position.Position, quantity float64, level int) error {
+	return e.reduce(p, quantity, fmt.Sprintf("tp%d", level))
+}
+
+// CloseAllPositions calls CloseOrder for every open position, returning an error listing the positions
+// that could not be closed.
+func (e *Exchange) CloseAllPositions(openPositions []*position.Position) error {
+	var failedPositions []string
+
+	for _, p := range openPositions {
+		if err := e.CloseOrder(p); err != nil {
+			e.Error().Str("err", err.Error()).Int("ID", p.ID).Str("Symbol", p.Symbol).Msg("Could not close position")
+			failedPositions = append(failedPositions, fmt.Sprintf("#%d %s", p.ID, p.Symbol))
+		}
+	}
+
+	if len(failedPositions) > 0 {
+		return fmt.Errorf("could not close %s", strings.Join(failedPositions, ", "))
+	}
+
+	return nil
+}
 
+// reduce sends a reduce only market order of quantity of the position, for action (e.g., "close").
+func (e *Exchange) reduce(p *position.Position, quantity float64, action string) error {
 	side := futures.SideTypeSell
 	if p.Side == analysis.SELL {
 		side = futures.SideTypeBuy
 	}
 
-	finalQuantity := strconv.FormatFloat(quantity, 'f', asset.QuantityPrecision, 64)
-
 	// NOTE: API wrapper doesn't store the executed price and quantity (may be slightly off from targets).
-	order, err := e.NewCreateOrderService().
-		Symbol(asset.Symbol).Side(side).Type(futures.OrderTypeMarket).Quantity(finalQuantity).
-		ReduceOnly(true).Do(context.Background())
+	orderID, err := e.placeOrder(p, quantity, 0, side, true, clientOrderID(p, action))
 	if err != nil {
-		e.Fatal().Str("err", err.Error()).Msg("Crashed creating Binance order")
+		return err
 	}
 
-	fmt.Println(order)
+	e.Info().Int64("OrderID", orderID).Int("ID", p.ID).Str("Symbol", p.Symbol).Msg("💳 Sent order")
 
-	e.Info().Int64("OrderID", order.OrderID).Str("Symbol", asset.Symbol).Msg("💳 Sent order")
+	return nil
 }
 
-// CloseAllPositions calls CloseOrder for every open position.
-func (e *Exchange) CloseAllPositions(openPositions []*position.Position) {
-	for _, p := range openPositions {
-		e.CloseOrder(p)
+// placeOrder sends an order of quantity for the position, retrying transient failures: a market order,
+// or a post-only limit order at price when not 0. Every attempt uses the same client order ID and retries
+// first look the order up by it, so that an order that reached the exchange despite the error is not
+// placed twice. So do the first attempts of close pending positions, whose previous closing order may have
+// been filled. It returns the order's ID.
+func (e *Exchange) placeOrder(
+	p *position.Position, quantity float64, price float64, side futures.SideType, reduceOnly bool,
+	clientOrderID string,
+) (int64, error) {
+	var orderID int64
+
+	formattedQuantity := strconv.FormatFloat(quantity, 'f', p.Asset.QuantityPrecision, 64)
+
+	err := e.retryNow("creating Binance order", func(ctx context.Context, attempt int) error {
+		if attempt > 0 || p.ClosePending != "" {
+			order, err := e.NewGetOrderService().
+				Symbol(p.Symbol).OrigClientOrderID(clientOrderID).Do(ctx)
+			if err == nil && isPlaced(order.Status) {
+				orderID = order.OrderID
+				return nil
+			}
+		}
+
+		service := e.NewCreateOrderService().
+			Symbol(p.Symbol).Side(side).Type(futures.OrderTypeMarket).Quantity(formattedQuantity).
+			NewClientOrderID(clientOrderID)
+
+		if price != 0 {
+			service = service.Type(futures.OrderTypeLimit).TimeInForce(futures.TimeInForceTypeGTX).
+				Price(strconv.FormatFloat(price, 'f', p.Asset.PricePrecision, 64))
+		}
+
+		// In hedge mode, orders target the position of their side, which makes them reduce only when
+		// closing (Binance rejects the reduceOnly parameter then).
+		if e.hedge {
+			service = service.PositionSide(positionSide(p))
+		} else {
+			service = service.ReduceOnly(reduceOnly)
+		}
+
+		order, err := service.Do(ctx)
+		if err != nil {
+			return err
+		}
+
+		orderID = order.OrderID
+
+		return nil
+	})
+
+	return orderID, err
+}
+
+// retry calls do until it succeeds, fails with a non-transient error, or MAX_ATTEMPTS are made, waiting
+// BACKOFF (doubled every time) between attempts. It returns the last error.
+func (e *Exchange) retry(description string, do func(ctx context.Context, attempt int) error) error {
+	return e.attempt(description, MAX_ATTEMPTS, BACKOFF, do)
+}
+
+// retryNow is retry for the requests made while the engine is locked: ORDER_ATTEMPTS are made without
+// waiting between them, so that a failing exchange does not block the engine (e.g., Telegram commands).
+func (e *Exchange) retryNow(description string, do func(ctx context.Context, attempt int) error) error {
+	return e.attempt(description, ORDER_ATTEMPTS, 0, do)
+}
+
+// attempt calls do up to attempts times until it succeeds or fails with a non-transient error, waiting
+// backoff (doubled every time) between attempts. Every attempt is given REQUEST_TIMEOUT to complete.
+func (e *Exchange) attempt(
+	description string, attempts int, backoff time.Duration, do func(ctx context.Context, attempt int) error,
+) error {
+	var err error
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 && backoff > 0 {
+			time.Sleep(backoff << (attempt - 1))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), REQUEST_TIMEOUT)
+		err = do(ctx, attempt)
+		cancel()
+
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+
+		e.Warn().Str("err", err.Error()).Int("attempt", attempt+1).Msg("Failed " + description)
+	}
+
+	return err
+}
+
+// clientOrderID returns the client order ID of the position's order for action (e.g., "open"), unique
+// per position and action (at most 36 characters, as required by Binance). The entry time, in base 36,
+// tells apart positions with the same ID in different sessions.
+func clientOrderID(p *position.Position, action string) string {
+	return fmt.Sprintf("%s-%s-%d-%s", p.Symbol, strconv.FormatInt(p.EntryTime.UnixMilli(), 36), p.ID, action)
+}
+
+// isPlaced returns whether an order with status went through (i.e., it should not be sent again).
+func isPlaced(status futures.OrderStatusType) bool {
+	switch status {
+	case futures.OrderStatusTypeFilled, futures.OrderStatusTypeNew, futures.OrderStatusTypePartiallyFilled:
+		return true
+	}
+
+	return false
+}
+
+// limitOrderID returns the client order ID of the attempt-th limit order of the position.
+func limitOrderID(p *position.Position, attempt int) string {
+	return clientOrderID(p, fmt.Sprintf("limit%d", attempt))
+}
+
+// positionSide returns the hedge mode position side of p.
+func positionSide(p *position.Position) futures.PositionSideType {
+	if p.Side == analysis.SELL {
+		return futures.PositionSideTypeShort
 	}
+
+	return futures.PositionSideTypeLong
+}
+
+// isRetryable returns whether err is transient: a network error or one of RETRYABLE_CODES.
+func isRetryable(err error) bool {
+	if apiErr, ok := err.(*common.APIError); ok {
+		return RETRYABLE_CODES[apiErr.Code]
+	}
+
+	return true
+}
+
+// parseFilter parses the value of an exchange info's filter, returning 0 when it is not a number.
+func parseFilter(value string) float64 {
+	parsed, _ := strconv.ParseFloat(value, 64)
+
+	return parsed
 }
@@ -0,0 +1,144 @@
+package journal
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"hermes/analysis"
+	"hermes/position"
+)
+
+// Journal records every closed trade to a CSV file and a JSON Lines file.
+type Journal struct {
+	csvFile   *os.File
+	csvWriter *csv.Writer
+	jsonFile  *os.File
+	mutex     sync.Mutex
+}
+
+// Entry is a closed trade as recorded in the journal.
+type Entry struct {
+	ID          int                 `json:"id"`
+	Symbol      string              `json:"symbol"`
+	Side        string              `json:"side"`
+	EntryTime   time.Time           `json:"entryTime"`
+	EntryPrice  float64             `json:"entryPrice"`
+	ExitTime    time.Time           `json:"exitTime"`
+	ExitPrice   float64             `json:"exitPrice"`
+	Holding     float64             `json:"holdingSeconds"`
+	Size        float64             `json:"size"`
+	Quantity    float64             `json:"quantity"`
+	EntrySignal string              `json:"entrySignal"`
+	ExitSignal  string              `json:"exitSignal"`
+	Fees        float64             `json:"fees"`
+	Funding     float64             `json:"funding"`
+	NetPNL      float64             `json:"netPNL"`
+	PNL         float64             `json:"pnl"`
+	Indicators  analysis.Indicators `json:"indicators"` // Snapshot at entry.
+}
+
+var header = []string{
+	"id", "symbol", "side", "entry_time", "entry_price", "exit_time", "exit_price", "holding_seconds",
+	"size", "quantity", "entry_signal", "exit_signal", "fees", "funding", "net_pnl", "pnl",
+	"trend", "ema_cross", "rsi", "rsi_signal", "ema_050", "ema_100", "ema_200",
+}
+
+// New creates the journal's files, named after the session's start time t (e.g.,
+// ./journal_2022-01-02T15:04:05.csv and .jsonl), and writes the CSV header.
+func New(t time.Time) (*Journal, error) {
+	name := fmt.Sprintf("./journal_%d-%02d-%02dT%02d:%02d:%02d",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(),
+	)
+
+	csvFile, err := os.Create(name + ".csv")
+	if err != nil {
+		return nil, err
+	}
+
+	jsonFile, err := os.Create(name + ".jsonl")
+	if err != nil {
+		csvFile.Close()
+		return nil, err
+	}
+
+	j := &Journal{csvFile: csvFile, csvWriter: csv.NewWriter(csvFile), jsonFile: jsonFile}
+
+	if err := j.writeCSV(header); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// Record appends the closed position to both files.
+func (j *Journal) Record(p *position.Position) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	entry := Entry{
+		ID:          p.ID,
+		Symbol:      p.Symbol,
+		Side:        p.Side,
+		EntryTime:   p.EntryTime,
+		EntryPrice:  p.EntryPrice,
+		ExitTime:    p.ExitTime,
+		ExitPrice:   p.ExitPrice,
+		Holding:     p.HoldingDuration(p.ExitTime).Seconds(),
+		Size:        p.Size,
+		Quantity:    p.Quantity,
+		EntrySignal: p.EntrySignal,
+		ExitSignal:  p.ExitSignal,
+		Fees:        p.Fees,
+		Funding:     p.Funding,
+		NetPNL:      p.NetPNL,
+		PNL:         p.PNL,
+		Indicators:  p.Indicators,
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := j.jsonFile.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	return j.writeCSV([]string{
+		strconv.Itoa(entry.ID), entry.Symbol, entry.Side,
+		entry.EntryTime.Format(time.RFC3339), formatFloat(entry.EntryPrice),
+		entry.ExitTime.Format(time.RFC3339), formatFloat(entry.ExitPrice), formatFloat(entry.Holding),
+		formatFloat(entry.Size), formatFloat(entry.Quantity),
+		entry.EntrySignal, entry.ExitSignal, formatFloat(entry.Fees), formatFloat(entry.Funding),
+		formatFloat(entry.NetPNL), formatFloat(entry.PNL),
+		entry.Indicators.Trend, entry.Indicators.EMACross,
+		formatFloat(entry.Indicators.RSI), entry.Indicators.RSISignal,
+		formatFloat(entry.Indicators.EMA_050), formatFloat(entry.Indicators.EMA_100),
+		formatFloat(entry.Indicators.EMA_200),
+	})
+}
+
+// Files returns the paths of the journal's files.
+func (j *Journal) Files() []string {
+	return []string{j.csvFile.Name(), j.jsonFile.Name()}
+}
+
+// writeCSV writes and flushes the record so that the file is complete at any time.
+func (j *Journal) writeCSV(record []string) error {
+	if err := j.csvWriter.Write(record); err != nil {
+		return err
+	}
+
+	j.csvWriter.Flush()
+
+	return j.csvWriter.Error()
+}
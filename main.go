@@ -1,16 +1,26 @@
 package main
 
 import (
+	"fmt"
 	"math"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"hermes/account"
 	"hermes/analysis"
+	"hermes/chart"
+	"hermes/entry"
 	"hermes/exchange"
+	"hermes/journal"
+	"hermes/notifier"
 	"hermes/position"
+	"hermes/risk"
+	"hermes/sizing"
 	"hermes/telegram"
 	"hermes/utils"
 
@@ -19,8 +29,19 @@ import (
 )
 
 const LIMIT int = 200
+const FUNDING_REFRESH = time.Minute  // Interval to refresh the funding rates fetched from Binance.
+const CLOSE_RETRY = 30 * time.Second // Interval to retry closing orders that failed.
+const ORDER_POLL = 5 * time.Second   // Interval to check pending limit entries on Binance.
+
+// Values for the engine's mode.
+const (
+	ACTIVE = "active" // Opens and manages positions.
+	PAUSED = "paused" // Manages open positions but opens no new ones.
+	HALTED = "halted" // Closed all positions after /panic and opens no new ones.
+)
 
 // CLI flags
+var flags utils.Flags
 var initialBalance float64
 var interval string
 var maxPositions int
@@ -29,20 +50,1025 @@ var onDev, trackPositions, isReal, sendSignals bool
 var acct account.Account
 var alerts []analysis.Alert
 var alertSymbols []string
-var bot telegram.Bot
+var bot *telegram.Bot // nil when running headless (i.e., without Telegram).
+var eng = engine{closeRetries: make(map[int]time.Time), mode: ACTIVE, startedAt: time.Now()}
 var excg exchange.Exchange
+var fundingRates position.FundingRates // Recorded funding rates (nil to use the rates fetched from Binance).
+var jrnl *journal.Journal
 var log zerolog.Logger = utils.InitLogging()
-var openPositions = make(map[string]*position.Position) // Used to easily add/delete open positions.
-var triggeredSignals = make(map[string]string)          // {"BTCUSDT": "bullish|bearish", ...}
-var symbolAssets = make(map[string]analysis.Asset)      // Symbol-to-asset mapping.
-var symbolCloses = make(map[string][]float64)           // {"BTCUSDT": [40004.75, ...], ...}
-var symbolPrices = make(map[string]float64)             // {"BTCUSDT": 40004.75, ...}
+var marginSetUp = make(map[string]bool) // Symbols whose leverage and margin type are set on Binance.
+var notif notifier.Notifier
+var riskManager *risk.Manager
+var openPositions = make(map[int]*position.Position)   // Open positions by ID. Used to easily add/delete them.
+var pendingEntries = make(map[int]*entry.Order)        // Limit entries waiting to be filled, by position ID.
+var triggeredSignals = make(map[string]string)         // {"BTCUSDT": "bullish|bearish", ...}
+var symbolAssets = make(map[string]analysis.Asset)     // Symbol-to-asset mapping.
+var symbolCandles = make(map[string][]analysis.Candle) // {"BTCUSDT": [{Open: 40004.75, ...}, ...], ...}
+var symbolCloses = make(map[string][]float64)          // {"BTCUSDT": [40004.75, ...], ...}
+var symbolMargins map[string]position.MarginSettings   // Overrides of the default margin settings (margin.json).
+var symbolPrices = make(map[string]float64)            // {"BTCUSDT": 40004.75, ...}
+
+// engine implements telegram.Engine. Its lock guards the trading state shared between the WebSocket
+// handler and the Telegram commands.
+type engine struct {
+	sync.Mutex
+	closeRetries map[int]time.Time  // Time to retry the failed closing orders (closes, take-profits), by ID.
+	clock        time.Time          // Event time of the last kline, used to timestamp positions.
+	fundingRates map[string]float64 // Funding rates to be paid on nextFunding, fetched from Binance.
+	lastKline    time.Time          // Time the last kline was received.
+	mode         string             // ACTIVE, PAUSED, HALTED.
+	nextFunding  time.Time          // Next funding time (zero until the first kline is received).
+	startedAt    time.Time          // Time the session started.
+	symbols      int                // Count of symbols streamed.
+}
+
+// Analyze runs the analysis of symbol on its stored candles.
+func (e *engine) Analyze(symbol string) (*analysis.Analysis, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	asset, hasAsset := symbolAssets[symbol]
+	closes := symbolCloses[symbol]
+	if !hasAsset || len(closes) != LIMIT {
+		return nil, fmt.Errorf("%s is not streamed", symbol)
+	}
+
+	a := analysis.New(&asset, closes, LIMIT-1)
+
+	return &a, nil
+}
+
+// Chart renders a PNG chart of symbol's stored candles, with the levels of p if not nil.
+func (e *engine) Chart(symbol string, p *position.Position) ([]byte, error) {
+	e.Lock()
+	candles := append([]analysis.Candle(nil), symbolCandles[symbol]...) // Copied to render unlocked.
+	if p != nil {
+		copied := *p
+		p = &copied
+	}
+	e.Unlock()
+
+	if len(candles) != LIMIT {
+		return nil, fmt.Errorf("%s is not streamed", symbol)
+	}
+
+	return chart.Render(symbol, interval, candles, p)
+}
+
+// JournalFiles returns the paths of the trade journal's files.
+func (e *engine) JournalFiles() []string {
+	return jrnl.Files()
+}
+
+// Snapshot returns a copy of the account and of the symbols' last prices, for commands to read unlocked.
+func (e *engine) Snapshot() (account.Account, map[string]float64) {
+	e.Lock()
+	defer e.Unlock()
+
+	prices := make(map[string]float64, len(symbolPrices))
+	for symbol, price := range symbolPrices {
+		prices[symbol] = price
+	}
+
+	return acct.Snapshot(), prices
+}
+
+// Price returns the last price of symbol.
+func (e *engine) Price(symbol string) (float64, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	price, hasPrice := symbolPrices[symbol]
+	if !hasPrice {
+		return 0, fmt.Errorf("%s is not streamed", symbol)
+	}
+
+	return price, nil
+}
+
+// now returns the engine's clock, or the current time before any kline has been received.
+func (e *engine) now() time.Time {
+	if e.clock.IsZero() {
+		return time.Now()
+	}
+
+	return e.clock
+}
+
+// Status returns a snapshot of the engine's health.
+func (e *engine) Status() telegram.Status {
+	e.Lock()
+	defer e.Unlock()
+
+	return telegram.Status{
+		LastKline:      e.lastKline,
+		Breakers:       riskManager.Tripped(),
+		Mode:           e.mode,
+		PendingEntries: len(pendingEntries),
+		SendsSignals:   sendSignals,
+		StartedAt:      e.startedAt,
+		Symbols:        e.symbols,
+	}
+}
+
+// Settings returns the effective settings of the session.
+func (e *engine) Settings() map[string]string {
+	e.Lock()
+	defer e.Unlock()
+
+	fundingRatesSource := "binance"
+	if flags.FundingRates != "" {
+		fundingRatesSource = flags.FundingRates
+	}
+
+	notifiers := os.Getenv("NOTIFIERS")
+	if notifiers == "" {
+		notifiers = notifier.TELEGRAM
+	}
+
+	entryMode := flags.Entry.Mode
+	if entryMode == entry.LIMIT {
+		entryMode = fmt.Sprintf(
+			"limit (%g%% offset, %s timeout, %d re-prices, %s fallback)",
+			flags.Entry.Offset*100, flags.Entry.Timeout, flags.Entry.Reprices, flags.Entry.Fallback,
+		)
+	}
+
+	takeProfits := "none"
+	if len(flags.TakeProfits) >= 1 {
+		levels := make([]string, len(flags.TakeProfits))
+		for i, level := range flags.TakeProfits {
+			levels[i] = fmt.Sprintf("%g%% @ +%g%%", level.Fraction*100, level.Distance*100)
+		}
+
+		takeProfits = strings.Join(levels, ", ")
+	}
+
+	return map[string]string{
+		"balance":        strconv.FormatFloat(initialBalance, 'f', 2, 64),
+		"dev":            strconv.FormatBool(onDev),
+		"entry":          entryMode,
+		"fees":           fmt.Sprintf("%g%% maker, %g%% taker", flags.Fees.Maker*100, flags.Fees.Taker*100),
+		"funding-rates":  fundingRatesSource,
+		"hedge":          strconv.FormatBool(flags.Hedge),
+		"interval":       interval,
+		"leverage":       strconv.Itoa(flags.Margin.Leverage),
+		"margin-type":    flags.Margin.MarginType,
+		"max-holding":    flags.MaxHolding.String(),
+		"max-daily-loss": fmt.Sprintf("%g%%", flags.Risk.MaxDailyLoss*100),
+		"max-drawdown":   fmt.Sprintf("%g%%", flags.Risk.MaxDrawdown*100),
+		"max-entries":    strconv.Itoa(flags.MaxEntries),
+		"max-exposure":   fmt.Sprintf("%g%%", flags.Risk.MaxExposure*100),
+		"max-losses":     strconv.Itoa(flags.Risk.MaxLosses),
+		"max-positions":  strconv.Itoa(maxPositions),
+		"notifiers":      strings.ToLower(notifiers),
+		"positions":      strconv.FormatBool(trackPositions),
+		"real":           strconv.FormatBool(isReal),
+		"signals":        strconv.FormatBool(sendSignals),
+		"sizing":         fmt.Sprintf("%s (%g)", flags.Sizing.Mode, flags.Sizing.Value),
+		"sl":             fmt.Sprintf("%g%%", position.SL*100),
+		"take-profits":   takeProfits,
+		"tp":             fmt.Sprintf("%g%%", position.TP*100),
+		"trailing":       fmt.Sprintf("%g%%", flags.Trailing*100),
+	}
+}
+
+// Mode returns the engine's current mode and whether signals are sent.
+func (e *engine) Mode() (string, bool) {
+	e.Lock()
+	defer e.Unlock()
+
+	return e.mode, sendSignals
+}
+
+// Pause stops opening new positions while still managing the open ones.
+func (e *engine) Pause() {
+	e.setMode(PAUSED)
+}
+
+// Resume goes back to opening new positions.
+func (e *engine) Resume() {
+	e.setMode(ACTIVE)
+
+	e.Lock()
+	defer e.Unlock()
+
+	if reset := riskManager.Reset(&acct); len(reset) > 0 {
+		notifyBreakers(nil, reset)
+	}
+}
+
+// Panic cancels the pending limit entries, closes all open positions, and stops opening new ones until
+// resumed.
+func (e *engine) Panic() []*position.Position {
+	e.setMode(HALTED)
+
+	e.Lock()
+	cancelEntries()
+	e.Unlock()
+
+	return e.CloseAllPositions("PANIC")
+}
+
+// Shutdown cancels the pending limit entries and, when real, closes the open positions on Binance before
+// exiting, sending the session's results when finish is true. The results are sent from a snapshot, unlocked,
+// as the Telegram sender may need the lock to draw charts while flushing.
+func (e *engine) Shutdown(finish bool) {
+	e.Lock()
+
+	cancelEntries()
+
+	if isReal {
+		if err := excg.CloseAllPositions(acct.OpenPositions); err != nil {
+			log.Error().Str("err", err.Error()).Msg("⚠️ Close the remaining positions manually")
+			notif.SendMessage(fmt.Sprintf("⚠️ *EXITING WITH OPEN POSITIONS*: %s", err))
+		}
+	}
+
+	e.Unlock()
+
+	final, prices := e.Snapshot()
+
+	if finish {
+		notif.SendFinish(&final, prices)
+		notif.Flush()
+	}
+
+	log.Info().
+		Float64("AllocatedBalance", final.AllocatedBalance).
+		Float64("AvailableBalance", final.AvailableBalance).
+		Float64("TotalBalance", final.TotalBalance).
+		Float64("NetPNL", final.NetPNL).
+		Float64("PNL", final.PNL).
+		Int("Loses", final.Loses).
+		Int("Wins", final.Wins).
+		Msg("📄")
+}
+
+// SetSignals turns sending signals on or off.
+func (e *engine) SetSignals(on bool) {
+	e.Lock()
+	defer e.Unlock()
+
+	sendSignals = on
+
+	log.Warn().Bool("signals", on).Msg("🚦 Toggled signals")
+}
+
+func (e *engine) setMode(mode string) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.mode = mode
+
+	log.Warn().Str("mode", mode).Msg("🚦 Changed mode")
+}
+
+// ClosePosition closes the open position with the ID passed at its symbol's last price.
+func (e *engine) ClosePosition(id int, exitSignal string) (*position.Position, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	p, isOpen := openPositions[id]
+	if !isOpen {
+		return nil, fmt.Errorf("no open position #%d", id)
+	}
+
+	if err := closePosition(p, symbolPrices[p.Symbol], exitSignal); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// CloseAllPositions closes every open position at its symbol's last price, returning the ones closed
+// (i.e., not left close pending).
+func (e *engine) CloseAllPositions(exitSignal string) []*position.Position {
+	e.Lock()
+	defer e.Unlock()
+
+	var closedPositions []*position.Position
+
+	for _, p := range openPositions {
+		if err := closePosition(p, symbolPrices[p.Symbol], exitSignal); err == nil {
+			closedPositions = append(closedPositions, p)
+		}
+	}
+
+	return closedPositions
+}
+
+// OpenPosition opens a manual position on symbol, using the default size, SL, and TP for the
+// values that are 0. With limit entries, the position is returned pending until its order is filled. Manual
+// positions are only opened while ACTIVE.
+func (e *engine) OpenPosition(symbol string, side string, size float64, sl float64, tp float64) (*position.Position, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.mode != ACTIVE {
+		return nil, fmt.Errorf("not opening new positions while %s (/resume first)", e.mode)
+	}
+
+	asset, hasAsset := symbolAssets[symbol]
+	closes := symbolCloses[symbol]
+	if !hasAsset || len(closes) != LIMIT {
+		return nil, fmt.Errorf("%s is not streamed", symbol)
+	}
+
+	if side != analysis.BUY && side != analysis.SELL {
+		return nil, fmt.Errorf("side should be %s or %s", analysis.BUY, analysis.SELL)
+	}
+
+	a := analysis.New(&asset, closes, LIMIT-1)
+	a.Side = side
+
+	quantity, size, err := sizePosition(&a, size, sl)
+	if err != nil {
+		return nil, err
+	}
+
+	p := position.New(&a, isReal, quantity, size, e.now())
+	p.EntrySignal = "MANUAL"
+	p.SetMargin(marginOf(symbol))
+	p.SetTakeProfits(flags.TakeProfits, flags.Trailing)
+
+	if sl != 0 {
+		p.SL = sl
+	}
+
+	if tp != 0 {
+		p.TP = tp
+	}
+
+	if err := p.CheckTargets(a.Price); err != nil {
+		return nil, err
+	}
+
+	if err := enterPosition(p, a.Price); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// UpdateTargets sets the SL and TP of the open position with the ID passed, rounded to the asset's tick
+// size, after checking them against its side and the symbol's last price. It returns a copy of the
+// position, as the position keeps changing.
+func (e *engine) UpdateTargets(id int, sl float64, tp float64) (*position.Position, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	p, isOpen := openPositions[id]
+	if !isOpen {
+		return nil, fmt.Errorf("no open position #%d", id)
+	}
+
+	sl, tp = p.Asset.NormalizePrice(sl), p.Asset.NormalizePrice(tp)
+
+	updated := *p
+	updated.SL, updated.TP = sl, tp
+
+	if err := updated.CheckTargets(symbolPrices[p.Symbol]); err != nil {
+		return nil, err
+	}
+
+	// NOTE: SL and TP are enforced by wsKlineHandler with market orders (there are no resting SL/TP
+	// orders on the exchange to replace), so updating the position is enough when real as well.
+	p.SL, p.TP = sl, tp
+
+	log.Info().
+		Int("ID", p.ID).
+		Str("Symbol", p.Symbol).
+		Float64("SL", p.SL).
+		Float64("TP", p.TP).
+		Msg("🎯 Updated targets")
+
+	return &updated, nil
+}
+
+// marginOf returns the leverage and margin type positions of symbol are opened with.
+func marginOf(symbol string) position.MarginSettings {
+	if margin, hasMargin := symbolMargins[symbol]; hasMargin {
+		return margin
+	}
+
+	return flags.Margin
+}
+
+// sizePosition returns the quantity and size (notional, USDT) of a new position for the analysis passed,
+// and an error if the quantity or any of the checks of checkPosition fail. When 0, the size is computed
+// according to flags.Sizing (using sl, or the default SL when 0, as the stop).
+func sizePosition(a *analysis.Analysis, size float64, sl float64) (float64, float64, error) {
+	asset, price := a.Asset, a.Price
+	leverage := float64(marginOf(a.Symbol).Leverage)
+
+	// NOTE: to be safer, may want to factor in unrealized PNL ([TotalBalance+uPNL] / maxPositions)
+	if size == 0 {
+		stopDistance := position.SL
+		if sl != 0 {
+			stopDistance = math.Abs(price-sl) / price
+		}
+
+		atr := analysis.ATR(symbolCandles[a.Symbol], sizing.ATR_PERIOD)
+		size = flags.Sizing.Size(acct.TotalBalance, leverage, price, stopDistance, atr)
+	}
+
+	quantity, err := sizing.Bound(asset, size/price, price, flags.Entry.OrderType())
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Shrink the size when the quantity was capped at the asset's maximum.
+	size = math.Min(size, math.Floor(quantity*price*100)/100)
+
+	if err := checkPosition(a.Symbol, a.Side, size); err != nil {
+		return 0, 0, err
+	}
+
+	return quantity, size, nil
+}
+
+// checkPosition returns an error if a new position of symbol on side with size (notional, USDT) fails any
+// of the hedge, entry, slot, balance, or risk checks. Pending limit entries count as open positions.
+func checkPosition(symbol string, side string, size float64) error {
+	positions := make([]*position.Position, 0, len(openPositions)+len(pendingEntries))
+	for _, p := range openPositions {
+		positions = append(positions, p)
+	}
+
+	pendingMargin, pendingLong := 0.0, 0.0 // Net long notional of the pending entries (negative when short).
+	for _, o := range pendingEntries {
+		positions, pendingMargin = append(positions, o.Position), pendingMargin+o.Position.Margin
+
+		if o.Position.Side == analysis.BUY {
+			pendingLong += o.Position.Size
+		} else {
+			pendingLong -= o.Position.Size
+		}
+	}
+
+	entries, hasOppositePosition := 0, false
+	for _, p := range positions {
+		if p.Symbol == symbol && p.Side == side {
+			entries += 1
+		} else if p.Symbol == symbol {
+			hasOppositePosition = true
+		}
+	}
+
+	switch {
+	case hasOppositePosition && !flags.Hedge:
+		return fmt.Errorf("%s has an open position on the other side (hedge mode is off)", symbol)
+	case entries >= flags.MaxEntries:
+		return fmt.Errorf(
+			"%s already has %d open %s positions (max entries: %d)", symbol, entries, side, flags.MaxEntries,
+		)
+	case len(positions) >= maxPositions:
+		return fmt.Errorf("no free slots (max positions: %d)", maxPositions)
+	}
+
+	if margin := size / float64(marginOf(symbol).Leverage); acct.AvailableBalance-pendingMargin < margin {
+		return fmt.Errorf("not enough balance for a $%.2f margin", margin)
+	}
+
+	return riskManager.Allows(side, size, pendingLong)
+}
+
+// enterPosition opens p at price according to flags.Entry: right away with a market order, or by placing a
+// limit order that wsKlineHandler follows up on (see checkEntries).
+func enterPosition(p *position.Position, price float64) error {
+	if flags.Entry.Mode == entry.MARKET {
+		return openPosition(p)
+	}
+
+	return placeEntry(&entry.Order{Position: p}, price)
+}
+
+// openPosition normalizes p to the asset's filters, sends the opening market order when real, records p
+// in the account, and notifies about it.
+func openPosition(p *position.Position) error {
+	if err := p.Normalize(analysis.MARKET_ORDER); err != nil {
+		log.Error().Str("err", err.Error()).Str("Symbol", p.Symbol).Msg("Could not normalize position")
+		return err
+	}
+
+	if isReal {
+		if err := setUpMargin(p.Symbol); err != nil {
+			return err
+		}
+
+		if err := excg.NewOrder(p); err != nil {
+			log.Error().Str("err", err.Error()).Str("Symbol", p.Symbol).Msg("Could not open position")
+			notif.SendMessage(fmt.Sprintf("❌ Could not open *%s*: %s", p.Symbol, err))
+
+			return fmt.Errorf("could not open %s: %w", p.Symbol, err)
+		}
+	}
+
+	recordPosition(p, flags.Fees.Taker)
+
+	return nil
+}
+
+// setUpMargin sets the leverage and margin type of symbol on Binance, once per session.
+func setUpMargin(symbol string) error {
+	if marginSetUp[symbol] {
+		return nil
+	}
+
+	if err := excg.SetMargin(symbol, marginOf(symbol)); err != nil {
+		log.Error().Str("err", err.Error()).Str("Symbol", symbol).Msg("Could not set margin")
+		return fmt.Errorf("could not set the margin of %s: %w", symbol, err)
+	}
+
+	marginSetUp[symbol] = true
+
+	return nil
+}
+
+// recordPosition charges the entry's commission at feeRate and records the opened position p in the
+// account, notifying about it.
+func recordPosition(p *position.Position, feeRate float64) {
+	p.ChargeFee(p.EntryPrice, feeRate)
+
+	openPositions[p.ID] = p
+
+	acct.LogNewPosition(p)
+	notif.SendNewPosition(p)
+
+	log.Info().
+		Str("EntrySignal", p.EntrySignal).
+		Float64("EntryPrice", p.EntryPrice).
+		Int("ID", p.ID).
+		Int("Leverage", p.Leverage).
+		Float64("Liquidation", p.Liquidation).
+		Str("MarginType", p.MarginType).
+		Float64("Quantity", p.Quantity).
+		Float64("Size", p.Size).
+		Int("Slots", maxPositions-len(openPositions)).
+		Str("Symbol", p.Symbol).
+		Float64("SL", p.SL).
+		Float64("TP", p.TP).
+		Msg("💡")
+
+	log.Info().
+		Float64("AllocatedBalance", acct.AllocatedBalance).
+		Float64("AvailableBalance", acct.AvailableBalance).
+		Float64("Notional", acct.Notional).
+		Msg("📄")
+}
+
+// placeEntry places the limit order o at flags.Entry.Offset from price (sending it when real), and keeps
+// it pending. The position's EntryPrice, Size, and Margin are set at the limit price.
+func placeEntry(o *entry.Order, price float64) error {
+	p := o.Position
+
+	o.Price, o.PlacedAt = flags.Entry.LimitPrice(p.Asset, p.Side, price), eng.now()
+	p.EntryPrice, p.Size = o.Price, p.Quantity*o.Price
+	p.SetMargin(marginOf(p.Symbol))
+
+	if err := p.Normalize(analysis.LIMIT_ORDER); err != nil {
+		log.Error().Str("err", err.Error()).Str("Symbol", p.Symbol).Msg("Could not normalize position")
+		return err
+	}
+
+	if err := p.Asset.ValidatePrice(o.Price, price); err != nil {
+		return err
+	}
+
+	if isReal {
+		if err := setUpMargin(p.Symbol); err != nil {
+			return err
+		}
+
+		if err := excg.NewLimitOrder(p, o.Price, o.Attempt); err != nil {
+			log.Error().Str("err", err.Error()).Str("Symbol", p.Symbol).Msg("Could not place limit entry")
+			notif.SendMessage(fmt.Sprintf("❌ Could not place a limit entry on *%s*: %s", p.Symbol, err))
+
+			return fmt.Errorf("could not place a limit entry on %s: %w", p.Symbol, err)
+		}
+
+		o.CheckedAt = time.Now()
+	}
+
+	pendingEntries[p.ID] = o
+
+	notif.SendPendingEntry(p, o.Attempt+1, flags.Entry.Reprices+1)
+
+	log.Info().
+		Int("Attempt", o.Attempt).
+		Int("ID", p.ID).
+		Float64("Price", o.Price).
+		Float64("Quantity", p.Quantity).
+		Str("Side", p.Side).
+		Str("Symbol", p.Symbol).
+		Msg("⏳ Placed limit entry")
+
+	return nil
+}
+
+// checkEntries follows up on the pending limit entries of symbol, given its last candle (started at
+// start). Simulated orders are filled at their price when the candle trades through it. Real orders are
+// checked on Binance every ORDER_POLL, and canceled once flags.Entry.Timeout is over, keeping whatever
+// was filled. Orders not filled in time are re-priced (see retryEntry).
+func checkEntries(symbol string, candle analysis.Candle, start time.Time) {
+	for _, o := range pendingEntriesOf(symbol) {
+		timedOut := eng.now().Sub(o.PlacedAt) >= flags.Entry.Timeout
+
+		if !isReal {
+			if o.Fills(candle, start) {
+				fillEntry(o, o.Price, o.Position.Quantity)
+			} else if timedOut {
+				retryEntry(o, candle.Close)
+			}
+
+			continue
+		}
+
+		if !timedOut && time.Since(o.CheckedAt) < ORDER_POLL {
+			continue
+		}
+
+		o.CheckedAt = time.Now()
+
+		fetch := excg.FetchLimitOrder
+		if timedOut {
+			fetch = excg.CancelLimitOrder
+		}
+
+		fill, err := fetch(o.Position, o.Attempt)
+		if err != nil {
+			log.Error().
+				Str("err", err.Error()).
+				Int("ID", o.Position.ID).
+				Str("Symbol", symbol).
+				Msg("Could not check limit entry")
+			continue
+		}
+
+		switch {
+		case fill.Done && fill.Quantity > 0:
+			fillEntry(o, fill.Price, fill.Quantity)
+		case fill.Done || timedOut:
+			retryEntry(o, candle.Close)
+		}
+	}
+}
+
+// pendingEntriesOf returns the pending limit entries of symbol, oldest first.
+func pendingEntriesOf(symbol string) []*entry.Order {
+	var orders []*entry.Order
+	for _, o := range pendingEntries {
+		if o.Position.Symbol == symbol {
+			orders = append(orders, o)
+		}
+	}
+
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Position.ID < orders[j].Position.ID })
+
+	return orders
+}
+
+// fillEntry opens the position of the limit entry o, filled with quantity at price. Limit entries pay
+// the maker commission.
+func fillEntry(o *entry.Order, price float64, quantity float64) {
+	p := o.Position
+
+	delete(pendingEntries, p.ID)
+
+	p.EntryPrice, p.EntryTime = price, eng.now()
+	p.Quantity, p.InitialQuantity, p.Size = quantity, quantity, quantity*price
+	p.SetMargin(marginOf(p.Symbol))
+	p.SetTakeProfits(flags.TakeProfits, flags.Trailing)
+
+	recordPosition(p, flags.Fees.Maker)
+}
+
+// retryEntry re-places the limit entry o at price while it has re-prices left. Otherwise it falls back to
+// flags.Entry.Fallback: opening the position with a market order, or abandoning it. The position is
+// checked again at its new price first (see checkPosition), and abandoned if it fails.
+func retryEntry(o *entry.Order, price float64) {
+	p := o.Position
+
+	delete(pendingEntries, p.ID)
+
+	err := fmt.Errorf("not filled")
+
+	switch {
+	case o.Attempt < flags.Entry.Reprices:
+		o.Attempt += 1
+
+		if err = checkPosition(p.Symbol, p.Side, p.Quantity*flags.Entry.LimitPrice(p.Asset, p.Side, price)); err != nil {
+			break
+		}
+
+		if err = placeEntry(o, price); err == nil {
+			return
+		}
+	case flags.Entry.Fallback == entry.MARKET:
+		if err = p.CheckTargets(price); err != nil {
+			break
+		}
+
+		if err = checkPosition(p.Symbol, p.Side, p.Quantity*price); err != nil {
+			break
+		}
+
+		p.EntryPrice, p.Size = price, p.Quantity*price
+		p.SetMargin(marginOf(p.Symbol))
+		p.SetTakeProfits(flags.TakeProfits, flags.Trailing)
+
+		if err = openPosition(p); err == nil {
+			return
+		}
+	}
+
+	notif.SendAbandonedEntry(p, err.Error())
+
+	log.Warn().
+		Str("err", err.Error()).
+		Int("Attempt", o.Attempt).
+		Int("ID", p.ID).
+		Str("Symbol", p.Symbol).
+		Msg("🚫 Abandoned limit entry")
+}
+
+// cancelEntries cancels every pending limit entry, opening the positions of the ones partially filled
+// meanwhile (real).
+func cancelEntries() {
+	for _, o := range pendingEntries {
+		p := o.Position
+
+		delete(pendingEntries, p.ID)
+
+		if !isReal {
+			continue
+		}
+
+		fill, err := excg.CancelLimitOrder(p, o.Attempt)
+		if err != nil {
+			log.Error().Str("err", err.Error()).Int("ID", p.ID).Str("Symbol", p.Symbol).Msg("Could not cancel limit entry")
+			notif.SendMessage(fmt.Sprintf("⚠️ Could not cancel the limit entry on *#%d %s*: %s", p.ID, p.Symbol, err))
+
+			continue
+		}
+
+		if fill.Quantity > 0 {
+			fillEntry(o, fill.Price, fill.Quantity)
+		}
+	}
+}
+
+// closePosition sends the closing order when real, closes p at price, records it in the account, and
+// notifies about it. When the closing order fails, p is left open and marked close pending, and
+// wsKlineHandler retries closing it every CLOSE_RETRY.
+func closePosition(p *position.Position, price float64, exitSignal string) error {
+	if isReal {
+		if err := excg.CloseOrder(p); err != nil {
+			if p.ClosePending == "" {
+				notif.SendMessage(fmt.Sprintf(
+					"⚠️ *CLOSE PENDING*: could not close *#%d %s* (%s), retrying every %s",
+					p.ID, p.Symbol, err, CLOSE_RETRY,
+				))
+			}
+
+			p.ClosePending = exitSignal
+			eng.closeRetries[p.ID] = time.Now().Add(CLOSE_RETRY)
+
+			log.Error().Str("err", err.Error()).Int("ID", p.ID).Str("Symbol", p.Symbol).Msg("⚠️ Could not close position")
+
+			return fmt.Errorf("could not close #%d %s (retrying): %w", p.ID, p.Symbol, err)
+		}
+	}
+
+	p.ClosePending = ""
+	delete(eng.closeRetries, p.ID)
+
+	p.Close(price, exitSignal, eng.now(), flags.Fees.Taker) // Exits are market orders.
+
+	acct.LogClosedPosition(p)
+
+	if err := jrnl.Record(p); err != nil {
+		log.Error().Str("err", err.Error()).Str("Symbol", p.Symbol).Msg("Could not record trade in journal")
+	}
+
+	delete(openPositions, p.ID)
+
+	notif.SendClosedPosition(p)
+
+	log.Info().
+		Dur("HoldingDuration", p.HoldingDuration(p.ExitTime)).
+		Str("ExitSignal", p.ExitSignal).
+		Float64("Fees", p.Fees).
+		Float64("Funding", p.Funding).
+		Int("ID", p.ID).
+		Float64("NetPNL", p.NetPNL).
+		Float64("PNL", p.PNL).
+		Float64("Price", price).
+		Int("Slots", maxPositions-len(openPositions)).
+		Str("Symbol", p.Symbol).
+		Msg(telegram.GetPNLEmoji(p.PNL) + " closed")
+
+	log.Info().
+		Float64("AllocatedBalance", acct.AllocatedBalance).
+		Float64("AvailableBalance", acct.AvailableBalance).
+		Float64("TotalBalance", acct.TotalBalance).
+		Float64("NetPNL", acct.NetPNL).
+		Float64("PNL", acct.PNL).
+		Int("Loses", acct.Loses).
+		Int("Wins", acct.Wins).
+		Msg("📄")
+
+	return nil
+}
+
+// takeProfit closes the portion of p set by its take-profit level at price, sending the closing order
+// when real, records it in the account, and notifies about it. The whole position is closed instead
+// when the rest would be too small to be closed later. Levels whose portion is too small are skipped,
+// leaving the rest to the next level, the SL, and the TP. When the order fails, the level is kept and
+// wsKlineHandler retries it every CLOSE_RETRY while the price is still past it.
+func takeProfit(p *position.Position, level position.TakeProfit, price float64) error {
+	hit := p.TakeProfitsHit + 1
+	exitSignal := fmt.Sprintf("TP%d", hit)
+
+	quantity := p.TakeProfitQuantity(level, price)
+
+	switch quantity {
+	case 0:
+		p.TakeProfitsHit = hit
+
+		log.Warn().
+			Str("ExitSignal", exitSignal).
+			Int("ID", p.ID).
+			Str("Symbol", p.Symbol).
+			Msg("Skipped take-profit: portion too small")
+
+		return fmt.Errorf("could not take profit on #%d %s: portion too small", p.ID, p.Symbol)
+	case p.Quantity:
+		p.TakeProfitsHit = hit
+
+		return closePosition(p, price, "TP")
+	}
+
+	if isReal {
+		if err := excg.PartialCloseOrder(p, quantity, hit); err != nil {
+			if _, isRetrying := eng.closeRetries[p.ID]; !isRetrying {
+				notif.SendMessage(fmt.Sprintf(
+					"⚠️ Could not take profit on *#%d %s* at %s (%s), retrying every %s",
+					p.ID, p.Symbol, exitSignal, err, CLOSE_RETRY,
+				))
+			}
+
+			eng.closeRetries[p.ID] = time.Now().Add(CLOSE_RETRY)
+
+			log.Error().Str("err", err.Error()).Int("ID", p.ID).Str("Symbol", p.Symbol).Msg("Could not take profit")
+
+			return fmt.Errorf("could not take profit on #%d %s (retrying): %w", p.ID, p.Symbol, err)
+		}
+	}
+
+	p.TakeProfitsHit = hit
+	delete(eng.closeRetries, p.ID)
+
+	// Exits are market orders.
+	part := p.PartialClose(quantity/p.Quantity, price, exitSignal, eng.now(), flags.Fees.Taker)
+
+	acct.LogPartialClose(part)
+
+	if err := jrnl.Record(part); err != nil {
+		log.Error().Str("err", err.Error()).Str("Symbol", p.Symbol).Msg("Could not record trade in journal")
+	}
+
+	notif.SendPartialClose(part, p)
+
+	log.Info().
+		Str("ExitSignal", part.ExitSignal).
+		Float64("Fees", part.Fees).
+		Float64("Funding", part.Funding).
+		Int("ID", p.ID).
+		Float64("NetPNL", part.NetPNL).
+		Float64("PNL", part.PNL).
+		Float64("Price", price).
+		Float64("Quantity", part.Quantity).
+		Float64("QuantityLeft", p.Quantity).
+		Str("Symbol", p.Symbol).
+		Msg(telegram.GetPNLEmoji(part.PNL) + " took profit")
+
+	log.Info().
+		Float64("AllocatedBalance", acct.AllocatedBalance).
+		Float64("AvailableBalance", acct.AvailableBalance).
+		Float64("TotalBalance", acct.TotalBalance).
+		Float64("NetPNL", acct.NetPNL).
+		Float64("PNL", acct.PNL).
+		Msg("📄")
+
+	return nil
+}
+
+// notifyBreakers notifies about the risk breakers that tripped and the ones that reset.
+func notifyBreakers(tripped []string, reset []string) {
+	for _, breaker := range tripped {
+		log.Warn().Str("breaker", breaker).Msg("🛑 Risk breaker tripped")
+		notif.SendMessage(fmt.Sprintf("🛑 *RISK BREAKER TRIPPED*: %s, not opening new positions", breaker))
+	}
+
+	for _, breaker := range reset {
+		log.Info().Str("breaker", breaker).Msg("✅ Risk breaker reset")
+		notif.SendMessage(fmt.Sprintf("✅ *RISK BREAKER RESET*: %s", breaker))
+	}
+}
+
+// settleFunding makes the open positions opened before the funding time t pay (or receive) funding at
+// the symbol's last price. Rates come from the recorded series when loaded, or from Binance otherwise.
+func settleFunding(t time.Time) {
+	for _, p := range openPositions {
+		if !p.EntryTime.Before(t) {
+			continue
+		}
+
+		rate, hasRate := eng.fundingRates[p.Symbol]
+		if fundingRates != nil {
+			rate, hasRate = fundingRates.Rate(p.Symbol, t)
+		}
+
+		if !hasRate {
+			log.Warn().Str("Symbol", p.Symbol).Time("at", t).Msg("No funding rate to settle")
+			continue
+		}
+
+		p.PayFunding(symbolPrices[p.Symbol], rate)
+
+		log.Info().
+			Float64("Funding", p.Funding).
+			Float64("Rate", rate).
+			Str("Symbol", p.Symbol).
+			Msg("🔁 Settled funding")
+	}
+}
+
+// refreshFundingRates fetches the funding rates from Binance every FUNDING_REFRESH.
+func refreshFundingRates() {
+	for {
+		if rates, err := excg.FetchFundingRates(); err != nil {
+			log.Error().Str("err", err.Error()).Msg("Could not fetch funding rates")
+		} else {
+			eng.Lock()
+			eng.fundingRates = rates
+			eng.Unlock()
+		}
+
+		time.Sleep(FUNDING_REFRESH)
+	}
+}
+
+// scheduleReports sends the daily report every day at flags.ReportTime, and the weekly report right
+// after it on flags.ReportDay.
+func scheduleReports() {
+	reportTime, _ := time.Parse("15:04", flags.ReportTime)
+
+	for {
+		now := time.Now()
+		next := time.Date(
+			now.Year(), now.Month(), now.Day(), reportTime.Hour(), reportTime.Minute(), 0, 0, now.Location(),
+		)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+
+		time.Sleep(time.Until(next))
+
+		eng.Lock()
+
+		notif.SendReport("DAILY", acct.Summarize(next.AddDate(0, 0, -1), next), &acct, symbolPrices)
+
+		if next.Weekday() == flags.ReportDay {
+			notif.SendReport("WEEKLY", acct.Summarize(next.AddDate(0, 0, -7), next), &acct, symbolPrices)
+		}
+
+		eng.Unlock()
+
+		log.Info().Time("at", next).Msg("📊 Sent reports")
+	}
+}
 
 // wsKlineHandler is called on every price update. It parses the passed kline, checks if a position
 // needs to be closed or opened, and if an alert or a signal is triggered.
 func wsKlineHandler(event *futures.WsKlineEvent) {
+	eng.Lock()
+	defer eng.Unlock()
+
 	k, symbol := event.Kline, event.Symbol
 
+	// Timestamp positions with the event time so that backtests are deterministic.
+	eng.clock, eng.lastKline = time.UnixMilli(event.Time), time.Now()
+
+	if eng.nextFunding.IsZero() {
+		eng.nextFunding = position.NextFundingTime(eng.clock)
+	} else if !eng.clock.Before(eng.nextFunding) {
+		settleFunding(eng.nextFunding)
+		eng.nextFunding = position.NextFundingTime(eng.clock)
+	}
+
 	parsedCandle := make(map[string]float64, 4)
 	rawCandle := map[string]string{
 		"Open": k.Open, "High": k.High, "Low": k.Low, "Close": k.Close,
@@ -59,19 +1085,24 @@ func wsKlineHandler(event *futures.WsKlineEvent) {
 
 	price := parsedCandle["Close"]
 
-	// NOTE: currently, only closes are updated (there may be TA indicators using other OHLC values)
-	closes := symbolCloses[symbol]
+	// NOTE: TA indicators only use closes; candles are kept for charts.
+	candles, closes := symbolCandles[symbol], symbolCloses[symbol]
 	closes[LIMIT-1] = price // Update the last candle
+	candles[LIMIT-1] = analysis.Candle{
+		Open: parsedCandle["Open"], High: parsedCandle["High"], Low: parsedCandle["Low"], Close: price,
+	}
 
 	// Rotate all candles but the last one (already set above).
 	if k.IsFinal {
 		// close[0] = close[1], ..., close[198] = close[199]
 		for i := 0; i < LIMIT-1; i++ {
+			candles[i] = candles[i+1]
 			closes[i] = closes[i+1]
 		}
 	}
 
 	// Update global maps
+	symbolCandles[symbol] = candles
 	symbolCloses[symbol] = closes
 	symbolPrices[symbol] = price
 
@@ -87,60 +1118,41 @@ func wsKlineHandler(event *futures.WsKlineEvent) {
 		Str("Trend", a.Trend).
 		Logger()
 
-	// NOTE: declaration not inlined in `if` so variable is accessible afterwards.
-	p, hasPositionWithSymbol := openPositions[symbol]
-	if hasPositionWithSymbol {
-		closed := false
+	checkEntries(symbol, candles[LIMIT-1], time.UnixMilli(k.StartTime))
 
-		// Check if position should be closed according to side and SL/TP.
-		if p.Side == analysis.BUY && price <= p.SL || p.Side == analysis.SELL && price >= p.SL {
-			closed = true
-			p.Close(price, "SL")
+	// Check if the symbol's positions should be closed according to their side and SL/TP.
+	for _, p := range acct.OpenPositionsOf(symbol) {
+		if p.ClosePending != "" {
+			if !time.Now().Before(eng.closeRetries[p.ID]) {
+				closePosition(p, price, p.ClosePending)
+			}
+		} else if p.Side == analysis.BUY && price <= p.SL || p.Side == analysis.SELL && price >= p.SL {
+			closePosition(p, price, "SL")
 		} else if p.Side == analysis.BUY && price >= p.TP || p.Side == analysis.SELL && price <= p.TP {
-			closed = true
-			p.Close(price, "TP")
+			closePosition(p, price, "TP")
+		} else if level, hitsLevel := p.NextTakeProfit(price); hitsLevel && !time.Now().Before(eng.closeRetries[p.ID]) {
+			takeProfit(p, level, price)
+		} else if flags.MaxHolding > 0 && p.HoldingDuration(eng.clock) >= flags.MaxHolding {
+			closePosition(p, price, "TIME")
+		} else {
+			p.Trail(price)
 		}
+	}
 
-		if closed {
-			if isReal {
-				excg.CloseOrder(p)
-			}
-
-			acct.LogClosedPosition(p)
-
-			delete(openPositions, symbol)
-
-			bot.SendClosedPosition(p)
-
-			sublogger.Info().
-				Str("ExitSignal", p.ExitSignal).
-				Float64("NetPNL", p.NetPNL).
-				Float64("PNL", p.PNL).
-				Int("Slots", maxPositions-len(openPositions)).
-				Msg(telegram.GetPNLEmoji(p.PNL) + " closed")
-
-			log.Info().
-				Float64("AllocatedBalance", acct.AllocatedBalance).
-				Float64("AvailableBalance", acct.AvailableBalance).
-				Float64("TotalBalance", acct.TotalBalance).
-				Float64("NetPNL", acct.NetPNL).
-				Float64("PNL", acct.PNL).
-				Int("Loses", acct.Loses).
-				Int("Wins", acct.Wins).
-				Msg("📄")
-		}
+	if tripped, reset := riskManager.Update(&acct, symbolPrices, eng.clock); len(tripped)+len(reset) > 0 {
+		notifyBreakers(tripped, reset)
 	}
 
 	// TODO: first, check if symbol has alert.
 	if triggersAlert, targetPrice := a.TriggersAlert(&alerts); triggersAlert {
 		sublogger.Info().Float64("TargetPrice", targetPrice).Msg("🔔")
 
-		bot.SendAlert(&a, targetPrice)
+		notif.SendAlert(&a, targetPrice)
 	}
 
 	if a.TriggersSignal(triggeredSignals) {
 		if sendSignals {
-			bot.SendSignal(&a)
+			notif.SendSignal(&a, !trackPositions)
 
 			sublogger.Info().
 				Str("EMA_Cross", a.EMACross).
@@ -149,41 +1161,14 @@ func wsKlineHandler(event *futures.WsKlineEvent) {
 				Msg("⚡")
 		}
 
-		// NOTE: to be safer, may want to factor in unrealized PNL ([TotalBalance+uPNL] / maxPositions)
-		// Round size to 2 digits
-		targetSize := math.Floor((acct.TotalBalance/float64(maxPositions))*100) / 100
-		targetQuantity := targetSize / price
-
-		hasEnoughBalance := acct.AvailableBalance >= targetSize
-		hasAFreeSlot := len(openPositions) < maxPositions
-		hasValidQuantity := targetQuantity >= asset.MinQuantity && targetQuantity <= asset.MaxQuantity
+		if trackPositions && eng.mode == ACTIVE {
+			if targetQuantity, targetSize, err := sizePosition(&a, 0, 0); err == nil {
+				p := position.New(&a, isReal, targetQuantity, targetSize, eng.clock)
+				p.SetMargin(marginOf(symbol))
+				p.SetTakeProfits(flags.TakeProfits, flags.Trailing)
 
-		if !hasPositionWithSymbol && hasEnoughBalance && hasAFreeSlot && hasValidQuantity && trackPositions {
-			p := position.New(&a, isReal, targetQuantity, targetSize)
-
-			if isReal {
-				excg.NewOrder(p)
+				enterPosition(p, a.Price)
 			}
-
-			openPositions[symbol] = p
-
-			acct.LogNewPosition(p)
-			bot.SendNewPosition(p)
-
-			log.Info().
-				Float64("EntryPrice", p.EntryPrice).
-				Float64("Quantity", p.Quantity).
-				Float64("Size", p.Size).
-				Int("Slots", maxPositions-len(openPositions)).
-				Str("Symbol", p.Symbol).
-				Float64("SL", p.SL).
-				Float64("TP", p.TP).
-				Msg("💡")
-
-			log.Info().
-				Float64("AllocatedBalance", acct.AllocatedBalance).
-				Float64("AvailableBalance", acct.AvailableBalance).
-				Msg("📄")
 		}
 
 		triggeredSignals[a.Symbol] = a.Side
@@ -191,16 +1176,31 @@ func wsKlineHandler(event *futures.WsKlineEvent) {
 }
 
 func init() {
-	initialBalance, onDev, interval, maxPositions, trackPositions, isReal, sendSignals = utils.ParseFlags(&log)
+	flags = utils.ParseFlags(&log)
+	initialBalance, onDev, interval, maxPositions = flags.Balance, flags.Dev, flags.Interval, flags.MaxPositions
+	trackPositions, isReal, sendSignals = flags.TrackPositions, flags.IsReal, flags.SendSignals
 
 	utils.LoadEnvFile(&log)
 
-	bot = telegram.New(&log, onDev)
+	var telegramNotifier notifier.Notifier // Left nil (not a nil *telegram.Bot) when headless.
 
-	excg = exchange.New(&bot, &log)
+	if telegramBot, err := telegram.New(&log, onDev); err != nil {
+		log.Warn().Str("err", err.Error()).Msg("📵 Running headless: Telegram is not available")
+	} else {
+		bot, telegramNotifier = telegramBot, telegramBot
+
+		bot.EnableCharts(eng.Chart)
+	}
+
+	notif = notifier.New(&log, telegramNotifier)
+
+	excg = exchange.New(&log)
 
 	if isReal {
-		initialBalance = excg.FetchBalance()
+		var err error
+		if initialBalance, err = excg.FetchBalance(); err != nil {
+			log.Fatal().Str("err", err.Error()).Msg("Crashed getting wallet balance")
+		}
 	}
 
 	if initialBalance < 5 {
@@ -210,6 +1210,26 @@ func init() {
 	}
 
 	acct = account.New(initialBalance, !trackPositions)
+
+	// Set the position mode on Binance even when one-way, as orders would be rejected if left in hedge mode.
+	if isReal {
+		if err := excg.SetHedgeMode(flags.Hedge); err != nil {
+			log.Fatal().Str("err", err.Error()).Bool("hedge", flags.Hedge).Msg("Crashed setting position mode")
+		}
+	}
+
+	riskManager = risk.New(flags.Risk, initialBalance)
+
+	symbolMargins = utils.LoadMarginSettings(&log, flags.Margin)
+
+	if flags.FundingRates != "" {
+		fundingRates = utils.LoadFundingRates(&log, flags.FundingRates)
+	}
+
+	var err error
+	if jrnl, err = journal.New(eng.startedAt); err != nil {
+		log.Fatal().Str("err", err.Error()).Msg("Crashed creating trade journal")
+	}
 }
 
 func main() {
@@ -221,23 +1241,29 @@ func main() {
 	signal.Notify(c, os.Interrupt) // Listen for CTRL-C.
 
 	go func() {
-		utils.HandleCTRLC(&acct, &bot, c, &excg, isReal, &log, symbolPrices, usesTelegramBot)
+		utils.HandleCTRLC(c, &log, func() { eng.Shutdown(usesTelegramBot) })
 	}()
 
 	log.Info().Str("interval", interval).Msg("📡 Fetching symbols...")
 
-	symbolIntervalPair := excg.FetchAssets(interval, LIMIT, symbolAssets, symbolCloses, &wg)
+	symbolIntervalPair, err := excg.FetchAssets(interval, LIMIT, symbolAssets, symbolCandles, symbolCloses, &wg)
+	if err != nil {
+		log.Fatal().Str("err", err.Error()).Msg("Crashed getting exchange info")
+	}
 
 	wg.Wait()
 
 	log.Info().Int("count", len(symbolIntervalPair)).Msg("🪙  Fetched symbols!")
 
+	eng.symbols = len(symbolIntervalPair)
+
 	alerts, alertSymbols = utils.LoadAlerts(&log, interval, symbolIntervalPair)
 	log.Info().Int("count", len(alerts)).Msg("⚙️  Loaded alerts")
 
 	errHandler := func(err error) {
 		msg := "💥 WebSocket stream crashed"
-		bot.SendMessage(msg)
+		notif.SendMessage(msg)
+		notif.Flush()
 		log.Fatal().Str("err", err.Error()).Msg(msg)
 	}
 
@@ -249,17 +1275,38 @@ func main() {
 	log.Info().
 		Float64("balance", initialBalance).
 		Bool("dev", onDev).
+		Str("entry", flags.Entry.Mode).
+		Bool("hedge", flags.Hedge).
+		Int("leverage", flags.Margin.Leverage).
+		Str("margin-type", flags.Margin.MarginType).
+		Float64("maker-fee", flags.Fees.Maker).
+		Float64("taker-fee", flags.Fees.Taker).
+		Int("max-entries", flags.MaxEntries).
 		Int("max-positions", maxPositions).
 		Bool("positions", trackPositions).
 		Bool("real", isReal).
 		Bool("signals", sendSignals).
+		Str("sizing", flags.Sizing.Mode).
+		Float64("sizing-value", flags.Sizing.Value).
+		Int("take-profits", len(flags.TakeProfits)).
+		Float64("trailing", flags.Trailing).
 		Msg("🔌 WebSocket initialised!")
 
 	if usesTelegramBot {
-		bot.SendInit(initialBalance, interval, maxPositions, trackPositions, isReal)
+		notif.SendInit(initialBalance, interval, maxPositions, trackPositions, isReal)
+	}
+
+	if flags.ReportTime != "" {
+		go scheduleReports()
 	}
 
-	bot.Listen(&acct, symbolPrices)
+	if fundingRates == nil {
+		go refreshFundingRates()
+	}
+
+	if bot != nil {
+		bot.Listen(&eng)
+	}
 
 	<-doneC
 }
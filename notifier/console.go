@@ -0,0 +1,144 @@
+package notifier
+
+import (
+	"hermes/account"
+	"hermes/analysis"
+	"hermes/position"
+
+	"github.com/rs/zerolog"
+)
+
+// Console is a Notifier writing every event to the log. It is used when running headless (i.e.,
+// without Telegram), e.g., in CI, backtests, or on air-gapped boxes.
+type Console struct {
+	*zerolog.Logger
+}
+
+func (c *Console) SendMessage(text string) {
+	c.Info().Str("text", text).Msg("📣 message")
+}
+
+func (c *Console) SendInit(initialBalance float64, interval string, maxPositions int, trackPositions bool, isReal bool) {
+	c.Info().
+		Float64("initialBalance", initialBalance).
+		Str("interval", interval).
+		Int("maxPositions", maxPositions).
+		Bool("trackPositions", trackPositions).
+		Bool("isReal", isReal).
+		Msg("📣 new session started")
+}
+
+func (c *Console) SendAlert(a *analysis.Analysis, target float64) {
+	c.Info().Str("Symbol", a.Symbol).Float64("Price", a.Price).Float64("TargetPrice", target).Msg("📣 alert")
+}
+
+func (c *Console) SendSignal(a *analysis.Analysis, withOpenButton bool) {
+	c.Info().
+		Str("Symbol", a.Symbol).
+		Float64("Price", a.Price).
+		Str("EMACross", a.EMACross).
+		Str("RSISignal", a.RSISignal).
+		Str("Side", a.Side).
+		Msg("📣 signal")
+}
+
+func (c *Console) SendNewPosition(p *position.Position) {
+	c.Info().
+		Int("ID", p.ID).
+		Str("Symbol", p.Symbol).
+		Str("Side", p.Side).
+		Float64("EntryPrice", p.EntryPrice).
+		Float64("Size", p.Size).
+		Int("Leverage", p.Leverage).
+		Str("MarginType", p.MarginType).
+		Float64("Liquidation", p.Liquidation).
+		Float64("SL", p.SL).
+		Float64("TP", p.TP).
+		Msg("📣 opened position")
+}
+
+func (c *Console) SendClosedPosition(p *position.Position) {
+	c.Info().
+		Int("ID", p.ID).
+		Str("Symbol", p.Symbol).
+		Str("Side", p.Side).
+		Float64("ExitPrice", p.ExitPrice).
+		Str("ExitSignal", p.ExitSignal).
+		Dur("HoldingDuration", p.HoldingDuration(p.ExitTime)).
+		Float64("Fees", p.Fees).
+		Float64("Funding", p.Funding).
+		Float64("NetPNL", p.NetPNL).
+		Float64("PNL", p.PNL).
+		Msg("📣 closed position")
+}
+
+func (c *Console) SendPartialClose(part *position.Position, p *position.Position) {
+	c.Info().
+		Int("ID", part.ID).
+		Str("Symbol", part.Symbol).
+		Str("Side", part.Side).
+		Float64("ExitPrice", part.ExitPrice).
+		Str("ExitSignal", part.ExitSignal).
+		Float64("Quantity", part.Quantity).
+		Float64("Fees", part.Fees).
+		Float64("Funding", part.Funding).
+		Float64("NetPNL", part.NetPNL).
+		Float64("PNL", part.PNL).
+		Float64("QuantityLeft", p.Quantity).
+		Float64("SL", p.SL).
+		Msg("📣 partially closed position")
+}
+
+func (c *Console) SendPendingEntry(p *position.Position, attempt int, attempts int) {
+	c.Info().
+		Int("ID", p.ID).
+		Str("Symbol", p.Symbol).
+		Str("Side", p.Side).
+		Float64("Price", p.EntryPrice).
+		Int("Attempt", attempt).
+		Int("Attempts", attempts).
+		Msg("📣 placed limit entry")
+}
+
+func (c *Console) SendAbandonedEntry(p *position.Position, reason string) {
+	c.Info().
+		Int("ID", p.ID).
+		Str("Symbol", p.Symbol).
+		Str("Side", p.Side).
+		Str("reason", reason).
+		Msg("📣 abandoned limit entry")
+}
+
+func (c *Console) SendFinish(acct *account.Account, symbolPrices map[string]float64) {
+	unrealizedPNL, rawPNL := acct.CalculateUnrealizedPNL(symbolPrices)
+
+	c.Info().
+		Float64("NetPNL", acct.NetPNL).
+		Float64("PNL", acct.PNL).
+		Float64("UnrealizedNetPNL", unrealizedPNL).
+		Float64("UnrealizedPNL", rawPNL).
+		Msg("📣 session terminated")
+}
+
+func (c *Console) SendReport(
+	title string, summary account.Summary, acct *account.Account, symbolPrices map[string]float64,
+) {
+	unrealizedPNL, _ := acct.CalculateUnrealizedPNL(symbolPrices)
+
+	c.Info().
+		Str("title", title).
+		Time("From", summary.From).
+		Time("To", summary.To).
+		Int("Opened", summary.Opened).
+		Int("Closed", summary.Closed).
+		Float64("WinRate", summary.WinRate()).
+		Float64("NetPNL", summary.NetPNL).
+		Float64("Fees", summary.Fees).
+		Float64("Funding", summary.Funding).
+		Int("OpenPositions", len(acct.OpenPositions)).
+		Float64("UnrealizedNetPNL", unrealizedPNL).
+		Msg("📣 report")
+}
+
+// Flush does nothing: events are logged synchronously.
+func (c *Console) Flush() {}
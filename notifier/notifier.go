@@ -0,0 +1,164 @@
+package notifier
+
+import (
+	"os"
+	"strings"
+
+	"hermes/account"
+	"hermes/analysis"
+	"hermes/position"
+
+	"github.com/rs/zerolog"
+)
+
+// Notifier describes the user-facing events of a session. telegram.Bot is one implementation.
+type Notifier interface {
+	SendMessage(text string)
+	SendInit(initialBalance float64, interval string, maxPositions int, trackPositions bool, isReal bool)
+	SendAlert(a *analysis.Analysis, target float64)
+	SendSignal(a *analysis.Analysis, withOpenButton bool)
+	SendNewPosition(p *position.Position)
+	SendClosedPosition(p *position.Position)
+	SendPartialClose(part *position.Position, p *position.Position)   // part: portion closed, p: rest left open.
+	SendPendingEntry(p *position.Position, attempt int, attempts int) // p waits for its limit entry at EntryPrice.
+	SendAbandonedEntry(p *position.Position, reason string)
+	SendFinish(acct *account.Account, symbolPrices map[string]float64)
+	SendReport(title string, summary account.Summary, acct *account.Account, symbolPrices map[string]float64)
+	Flush() // Waits until all the notifications sent so far have been delivered.
+}
+
+// Values for the NOTIFIERS environment variable (comma-separated).
+const (
+	CONSOLE  = "console"  // Logs events. Used instead of TELEGRAM when the bot is unavailable.
+	DISCORD  = "discord"  // Requires DISCORD_WEBHOOK_URL.
+	SLACK    = "slack"    // Requires SLACK_WEBHOOK_URL.
+	TELEGRAM = "telegram" // Default.
+	WEBHOOK  = "webhook"  // Requires WEBHOOK_URL. Posts a JSON object per event.
+)
+
+// New returns a Notifier sending to every backend listed in the NOTIFIERS environment variable,
+// using bot for TELEGRAM. When bot is nil (i.e., Telegram is not configured), TELEGRAM falls back
+// to CONSOLE.
+func New(log *zerolog.Logger, bot Notifier) Notifier {
+	names := os.Getenv("NOTIFIERS")
+	if names == "" {
+		names = TELEGRAM
+	}
+
+	var notifiers Multi
+
+	for _, name := range strings.Split(names, ",") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case CONSOLE:
+			notifiers = append(notifiers, &Console{log})
+		case DISCORD:
+			notifiers = append(notifiers, NewWebhook(log, DISCORD, getURL(log, "DISCORD_WEBHOOK_URL")))
+		case SLACK:
+			notifiers = append(notifiers, NewWebhook(log, SLACK, getURL(log, "SLACK_WEBHOOK_URL")))
+		case TELEGRAM:
+			if bot == nil {
+				notifiers = append(notifiers, &Console{log})
+				continue
+			}
+
+			notifiers = append(notifiers, bot)
+		case WEBHOOK:
+			notifiers = append(notifiers, NewWebhook(log, WEBHOOK, getURL(log, "WEBHOOK_URL")))
+		default:
+			log.Fatal().Str("notifier", name).Msg("Unknown notifier in NOTIFIERS")
+		}
+	}
+
+	if len(notifiers) == 1 {
+		return notifiers[0]
+	}
+
+	return notifiers
+}
+
+// getURL returns the value of the environment variable key, crashing if it is not set.
+func getURL(log *zerolog.Logger, key string) string {
+	url := os.Getenv(key)
+	if url == "" {
+		log.Fatal().Msg(key + " is required by NOTIFIERS")
+	}
+
+	return url
+}
+
+// Multi is a Notifier sending every event to all of its notifiers.
+type Multi []Notifier
+
+func (m Multi) SendMessage(text string) {
+	for _, n := range m {
+		n.SendMessage(text)
+	}
+}
+
+func (m Multi) SendInit(initialBalance float64, interval string, maxPositions int, trackPositions bool, isReal bool) {
+	for _, n := range m {
+		n.SendInit(initialBalance, interval, maxPositions, trackPositions, isReal)
+	}
+}
+
+func (m Multi) SendAlert(a *analysis.Analysis, target float64) {
+	for _, n := range m {
+		n.SendAlert(a, target)
+	}
+}
+
+func (m Multi) SendSignal(a *analysis.Analysis, withOpenButton bool) {
+	for _, n := range m {
+		n.SendSignal(a, withOpenButton)
+	}
+}
+
+func (m Multi) SendNewPosition(p *position.Position) {
+	for _, n := range m {
+		n.SendNewPosition(p)
+	}
+}
+
+func (m Multi) SendClosedPosition(p *position.Position) {
+	for _, n := range m {
+		n.SendClosedPosition(p)
+	}
+}
+
+func (m Multi) SendPartialClose(part *position.Position, p *position.Position) {
+	for _, n := range m {
+		n.SendPartialClose(part, p)
+	}
+}
+
+func (m Multi) SendPendingEntry(p *position.Position, attempt int, attempts int) {
+	for _, n := range m {
+		n.SendPendingEntry(p, attempt, attempts)
+	}
+}
+
+func (m Multi) SendAbandonedEntry(p *position.Position, reason string) {
+	for _, n := range m {
+		n.SendAbandonedEntry(p, reason)
+	}
+}
+
+func (m Multi) SendFinish(acct *account.Account, symbolPrices map[string]float64) {
+	for _, n := range m {
+		n.SendFinish(acct, symbolPrices)
+	}
+}
+
+func (m Multi) SendReport(
+	title string, summary account.Summary, acct *account.Account, symbolPrices map[string]float64,
+) {
+	for _, n := range m {
+		n.SendReport(title, summary, acct, symbolPrices)
+	}
+}
+
+func (m Multi) Flush() {
+	for _, n := range m {
+		n.Flush()
+	}
+}
@@ -0,0 +1,235 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"hermes/account"
+	"hermes/analysis"
+	"hermes/position"
+
+	"github.com/rs/zerolog"
+)
+
+const QUEUE_SIZE = 256                 // Events waiting to be posted before dropping new ones.
+const FLUSH_TIMEOUT = 10 * time.Second // Maximum time to wait for the queue to be posted on Flush.
+
+// Webhook is a Notifier posting every event to an HTTP endpoint: a Discord or Slack incoming
+// webhook (as a Markdown message) or a generic one (as a JSON Event).
+type Webhook struct {
+	*zerolog.Logger
+	client *http.Client
+	kind   string       // DISCORD, SLACK, WEBHOOK.
+	queue  chan request // Requests, posted in order by a single goroutine (see processQueue).
+	url    string
+}
+
+// Event is the payload posted by a generic (WEBHOOK) Webhook.
+type Event struct {
+	Event    string             `json:"event"` // "message", "init", "alert", "signal", "new_position", "closed_position", "partial_close", "pending_entry", "abandoned_entry", "finish", "report".
+	Text     string             `json:"text"`  // Human-readable description of the event.
+	Time     time.Time          `json:"time"`
+	Analysis *analysis.Analysis `json:"analysis,omitempty"`
+	Account  *account.Account   `json:"account,omitempty"`
+	Position *position.Position `json:"position,omitempty"`
+	Summary  *account.Summary   `json:"summary,omitempty"`
+}
+
+// request is an entry of the queue: either the payload of an event to post or a flush marker.
+type request struct {
+	body    []byte
+	flushed chan struct{}
+}
+
+// NewWebhook creates a Webhook of the given kind posting to url and starts its sender goroutine.
+func NewWebhook(log *zerolog.Logger, kind string, url string) *Webhook {
+	w := &Webhook{
+		Logger: log,
+		client: &http.Client{Timeout: 10 * time.Second},
+		kind:   kind,
+		queue:  make(chan request, QUEUE_SIZE),
+		url:    url,
+	}
+
+	go w.processQueue()
+
+	return w
+}
+
+func (w *Webhook) SendMessage(text string) {
+	w.post(&Event{Event: "message", Text: text})
+}
+
+func (w *Webhook) SendInit(initialBalance float64, interval string, maxPositions int, trackPositions bool, isReal bool) {
+	w.post(&Event{Event: "init", Text: fmt.Sprintf(
+		"🍾 *NEW SESSION STARTED* 🍾\n"+
+			"💰 initial balance: *$%.2f* | ⏱ interval: *%s* | 🔝 max positions: %d | "+
+			"📟 track positions: %t | 💳 real trades: *%t*",
+		initialBalance, interval, maxPositions, trackPositions, isReal,
+	)})
+}
+
+func (w *Webhook) SendAlert(a *analysis.Analysis, target float64) {
+	w.post(&Event{Event: "alert", Analysis: a, Text: fmt.Sprintf(
+		"🔔 *%s* crossed %g | 🖋 Price: *%g* | 📊 Trend: _%s_ | 💪 RSI: %.2f",
+		a.Asset.BaseAsset, target, a.Price, a.Trend, a.RSI,
+	)})
+}
+
+// SendSignal posts the signal found in the analysis. withOpenButton is ignored (Telegram only).
+func (w *Webhook) SendSignal(a *analysis.Analysis, withOpenButton bool) {
+	w.post(&Event{Event: "signal", Analysis: a, Text: fmt.Sprintf(
+		"⚡️ *%s* | EMA cross: _%s_ | RSI: _%s_ | 🖋 Price: %g | 📊 Trend: _%s_ | 💪 RSI: %.2f | 🔮 Side: *%s*",
+		a.Asset.BaseAsset, a.EMACross, a.RSISignal, a.Price, a.Trend, a.RSI, a.Side,
+	)})
+}
+
+func (w *Webhook) SendNewPosition(p *position.Position) {
+	w.post(&Event{Event: "new_position", Position: p, Text: fmt.Sprintf(
+		"💡 Opened *#%d %s* | %s | 🖋 Entry @ %g with $%g | ⚖️ %dx %s | 💀 Liq.: %g | 🧨 SL: %g | 💎 TP: %g | "+
+			"📡 Signal: _%s_",
+		p.ID, p.Symbol, p.Side, p.EntryPrice, p.Size, p.Leverage, p.MarginType, p.Liquidation, p.SL, p.TP,
+		p.EntrySignal,
+	)})
+}
+
+func (w *Webhook) SendClosedPosition(p *position.Position) {
+	w.post(&Event{Event: "closed_position", Position: p, Text: fmt.Sprintf(
+		"💰 Closed *#%d %s* | %s | 🖋 Exit @ %g with $%g | *%s* hit | 🕰 Held for %s | "+
+			"💸 Fees: $%.2f | 🔁 Funding: $%.2f | PNL: *$%.2f* (%.2f%%)",
+		p.ID, p.Symbol, p.Side, p.ExitPrice, p.Size, p.ExitSignal,
+		p.HoldingDuration(p.ExitTime).Round(time.Second), p.Fees, p.Funding, p.NetPNL, p.PNL,
+	)})
+}
+
+func (w *Webhook) SendPartialClose(part *position.Position, p *position.Position) {
+	w.post(&Event{Event: "partial_close", Position: part, Text: fmt.Sprintf(
+		"✂️ Took *%.0f%%* of *#%d %s* | %s | 🖋 Exit @ %g with $%g | *%s* hit | "+
+			"💸 Fees: $%.2f | 🔁 Funding: $%.2f | PNL: *$%.2f* (%.2f%%) | 📦 Left: %g ($%.2f) | 🧨 SL: %g",
+		part.Quantity/part.InitialQuantity*100, part.ID, part.Symbol, part.Side, part.ExitPrice, part.Size,
+		part.ExitSignal, part.Fees, part.Funding, part.NetPNL, part.PNL, p.Quantity, p.Size, p.SL,
+	)})
+}
+
+func (w *Webhook) SendPendingEntry(p *position.Position, attempt int, attempts int) {
+	w.post(&Event{Event: "pending_entry", Position: p, Text: fmt.Sprintf(
+		"⏳ Placed a limit *%s* entry on *#%d %s* at %g (attempt %d/%d)",
+		p.Side, p.ID, p.Symbol, p.EntryPrice, attempt, attempts,
+	)})
+}
+
+func (w *Webhook) SendAbandonedEntry(p *position.Position, reason string) {
+	w.post(&Event{Event: "abandoned_entry", Position: p, Text: fmt.Sprintf(
+		"🚫 Abandoned the limit entry on *#%d %s*: %s", p.ID, p.Symbol, reason,
+	)})
+}
+
+func (w *Webhook) SendFinish(acct *account.Account, symbolPrices map[string]float64) {
+	unrealizedPNL, rawPNL := acct.CalculateUnrealizedPNL(symbolPrices)
+
+	w.post(&Event{Event: "finish", Account: acct, Text: fmt.Sprintf(
+		"‼️ *SESSION TERMINATED* ‼️\n"+
+			"Net PNL: *$%.2f* (%.2f%%) | Unrealized PNL: *$%.2f* (%.2f%%)",
+		acct.NetPNL, acct.PNL, unrealizedPNL, rawPNL,
+	)})
+}
+
+func (w *Webhook) SendReport(
+	title string, summary account.Summary, acct *account.Account, symbolPrices map[string]float64,
+) {
+	unrealizedPNL, _ := acct.CalculateUnrealizedPNL(symbolPrices)
+
+	w.post(&Event{Event: "report", Summary: &summary, Text: fmt.Sprintf(
+		"📊 *%s REPORT* (%s → %s) | Opened: %d | Closed: %d | Win rate: %.2f%% | Net PNL: *$%.2f* | "+
+			"Fees: $%.2f | Funding: $%.2f | Open positions: %d (uPNL: $%.2f)",
+		title, summary.From.Format("Jan 2 15:04"), summary.To.Format("Jan 2 15:04"),
+		summary.Opened, summary.Closed, summary.WinRate(), summary.NetPNL, summary.Fees, summary.Funding,
+		len(acct.OpenPositions), unrealizedPNL,
+	)})
+}
+
+// Flush waits until all the events posted so far have been sent or FLUSH_TIMEOUT has elapsed.
+func (w *Webhook) Flush() {
+	flushed := make(chan struct{})
+
+	w.enqueue(request{flushed: flushed})
+
+	select {
+	case <-flushed:
+	case <-time.After(FLUSH_TIMEOUT):
+		w.Warn().Str("kind", w.kind).Msg("Timed out flushing webhook")
+	}
+}
+
+// post timestamps the event and queues its payload. The payload is encoded right away, as the positions
+// and account of the event keep changing while it waits in the queue.
+func (w *Webhook) post(event *Event) {
+	event.Time = time.Now()
+
+	body, err := w.encode(event)
+	if err != nil {
+		w.Error().Str("err", err.Error()).Str("kind", w.kind).Msg("Could not encode webhook event")
+		return
+	}
+
+	w.enqueue(request{body: body})
+}
+
+// enqueue adds the request to the queue, dropping it if the queue is full so that callers never block.
+func (w *Webhook) enqueue(r request) {
+	select {
+	case w.queue <- r:
+	default:
+		w.Error().Str("kind", w.kind).Msg("Dropped webhook event: queue is full")
+	}
+}
+
+// processQueue posts the queued events in order.
+func (w *Webhook) processQueue() {
+	for r := range w.queue {
+		if r.flushed != nil {
+			close(r.flushed)
+			continue
+		}
+
+		if err := w.send(r.body); err != nil {
+			w.Error().Str("err", err.Error()).Str("kind", w.kind).Msg("Could not post webhook event")
+		}
+	}
+}
+
+// encode returns the payload of the event in the format expected by the webhook's kind.
+func (w *Webhook) encode(event *Event) ([]byte, error) {
+	var payload interface{}
+
+	switch w.kind {
+	case DISCORD:
+		// Discord uses **bold** where Telegram and Slack use *bold*.
+		payload = map[string]string{"content": strings.ReplaceAll(event.Text, "*", "**")}
+	case SLACK:
+		payload = map[string]string{"text": event.Text}
+	default:
+		payload = event
+	}
+
+	return json.Marshal(payload)
+}
+
+// send posts the payload body.
+func (w *Webhook) send(body []byte) error {
+	res, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("got status %s", res.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"hermes/analysis"
+	"hermes/position"
+
+	"github.com/rs/zerolog"
+)
+
+// recorder is a local webhook endpoint recording the bodies posted to it, answering with status.
+type recorder struct {
+	sync.Mutex
+	bodies [][]byte
+	status int
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.Lock()
+	r.bodies = append(r.bodies, body)
+	r.Unlock()
+
+	w.WriteHeader(r.status)
+}
+
+func (r *recorder) posted() [][]byte {
+	r.Lock()
+	defer r.Unlock()
+
+	return append([][]byte(nil), r.bodies...)
+}
+
+// newTestWebhook returns a Webhook of kind posting to a local server answering with status, and the log
+// it writes to.
+func newTestWebhook(t *testing.T, kind string, status int) (*Webhook, *recorder, *bytes.Buffer) {
+	endpoint := &recorder{status: status}
+	server := httptest.NewServer(endpoint)
+	t.Cleanup(server.Close)
+
+	logs := &bytes.Buffer{}
+	log := zerolog.New(logs)
+
+	return NewWebhook(&log, kind, server.URL), endpoint, logs
+}
+
+func TestWebhookPayloads(t *testing.T) {
+	tests := []struct {
+		name string
+		kind string
+		want map[string]string
+	}{
+		{"discord", DISCORD, map[string]string{"content": "💡 **bold** text"}},
+		{"slack", SLACK, map[string]string{"text": "💡 *bold* text"}},
+		{"generic", WEBHOOK, map[string]string{"event": "message", "text": "💡 *bold* text"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, endpoint, _ := newTestWebhook(t, tt.kind, http.StatusNoContent)
+
+			w.SendMessage("💡 *bold* text")
+			w.Flush()
+
+			bodies := endpoint.posted()
+			if len(bodies) != 1 {
+				t.Fatalf("posted %d bodies, want 1", len(bodies))
+			}
+
+			var payload map[string]interface{}
+			if err := json.Unmarshal(bodies[0], &payload); err != nil {
+				t.Fatalf("payload is not JSON: %s", bodies[0])
+			}
+
+			for key, want := range tt.want {
+				if payload[key] != want {
+					t.Errorf("payload[%q] = %v, want %q", key, payload[key], want)
+				}
+			}
+
+			if _, hasTime := payload["time"]; hasTime != (tt.kind == WEBHOOK) {
+				t.Errorf("payload has time = %t, want %t", hasTime, tt.kind == WEBHOOK)
+			}
+		})
+	}
+}
+
+func TestWebhookEvent(t *testing.T) {
+	w, endpoint, _ := newTestWebhook(t, WEBHOOK, http.StatusOK)
+
+	p := &position.Position{
+		Asset:       &analysis.Asset{},
+		EntryPrice:  100,
+		EntrySignal: "MANUAL",
+		ID:          7,
+		Quantity:    2,
+		Side:        analysis.BUY,
+		Symbol:      "BTCUSDT",
+	}
+
+	w.SendNewPosition(p)
+	p.Quantity = 1 // Changed after the event was posted: the payload keeps the quantity it was posted with.
+	w.Flush()
+
+	bodies := endpoint.posted()
+	if len(bodies) != 1 {
+		t.Fatalf("posted %d bodies, want 1", len(bodies))
+	}
+
+	var event Event
+	if err := json.Unmarshal(bodies[0], &event); err != nil {
+		t.Fatalf("payload is not an Event: %s", bodies[0])
+	}
+
+	if event.Event != "new_position" || !strings.Contains(event.Text, "#7 BTCUSDT") || event.Time.IsZero() {
+		t.Errorf("event = (%q, %q, %s), want new_position of #7 BTCUSDT with a time", event.Event, event.Text, event.Time)
+	}
+
+	if event.Position == nil || event.Position.ID != 7 || event.Position.Quantity != 2 {
+		t.Errorf("Position = %+v, want #7 with a quantity of 2", event.Position)
+	}
+}
+
+func TestWebhookNon2xx(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"ok", http.StatusOK, false},
+		{"no content", http.StatusNoContent, false},
+		{"bad request", http.StatusBadRequest, true},
+		{"rate limited", http.StatusTooManyRequests, true},
+		{"server error", http.StatusInternalServerError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, endpoint, logs := newTestWebhook(t, SLACK, tt.status)
+
+			w.SendMessage("first")
+			w.SendMessage("second")
+			w.Flush()
+
+			// Failures are logged, and the next events are still posted.
+			if n := len(endpoint.posted()); n != 2 {
+				t.Errorf("posted %d bodies, want 2", n)
+			}
+
+			wantLogged := 0
+			if tt.wantErr {
+				wantLogged = 2
+			}
+
+			if logged := strings.Count(logs.String(), "Could not post webhook event"); logged != wantLogged {
+				t.Errorf("logged %d failures, want %d", logged, wantLogged)
+			}
+		})
+	}
+}
+
+func TestWebhookFlush(t *testing.T) {
+	w, endpoint, _ := newTestWebhook(t, SLACK, http.StatusOK)
+
+	texts := []string{"1", "2", "3", "4", "5"}
+	for _, text := range texts {
+		w.SendMessage(text)
+	}
+
+	w.Flush()
+
+	// Every event posted before Flush was delivered by the time it returns, in order.
+	bodies := endpoint.posted()
+	if len(bodies) != len(texts) {
+		t.Fatalf("posted %d bodies, want %d", len(bodies), len(texts))
+	}
+
+	for i, body := range bodies {
+		var payload map[string]string
+		if err := json.Unmarshal(body, &payload); err != nil || payload["text"] != texts[i] {
+			t.Errorf("body %d = %s, want text %q", i, body, texts[i])
+		}
+	}
+
+	// Flushing an empty queue returns right away.
+	w.Flush()
+}
@@ -0,0 +1,66 @@
+package position
+
+import (
+	"hermes/analysis"
+	"time"
+)
+
+// FUNDING_INTERVAL is the time between funding payments on Binance USD-M perpetuals (00:00, 08:00 and
+// 16:00 UTC).
+const FUNDING_INTERVAL = 8 * time.Hour
+
+// FeeSchedule holds the commission rates charged on fills, as fractions of the notional.
+type FeeSchedule struct {
+	Maker float64 // Rate of orders adding liquidity (i.e., limit orders resting on the book).
+	Taker float64 // Rate of orders removing liquidity (i.e., market orders).
+}
+
+// FEE_TIERS are Binance USD-M's regular fee tiers, indexed by VIP level (0 to 9).
+var FEE_TIERS = []FeeSchedule{
+	{Maker: 0.000200, Taker: 0.000500},
+	{Maker: 0.000160, Taker: 0.000400},
+	{Maker: 0.000140, Taker: 0.000350},
+	{Maker: 0.000120, Taker: 0.000320},
+	{Maker: 0.000100, Taker: 0.000300},
+	{Maker: 0.000080, Taker: 0.000270},
+	{Maker: 0.000060, Taker: 0.000250},
+	{Maker: 0.000040, Taker: 0.000220},
+	{Maker: 0.000020, Taker: 0.000200},
+	{Maker: 0.000000, Taker: 0.000170},
+}
+
+// FundingRates is a recorded series of funding rates: {"BTCUSDT": {<funding time in ms>: 0.0001, ...}, ...}.
+type FundingRates map[string]map[int64]float64
+
+// Rate returns the funding rate of symbol at the funding time t, and whether it was recorded.
+func (fr FundingRates) Rate(symbol string, t time.Time) (float64, bool) {
+	rate, ok := fr[symbol][t.UnixMilli()]
+
+	return rate, ok
+}
+
+// NextFundingTime returns the first funding time after t.
+func NextFundingTime(t time.Time) time.Time {
+	return t.UTC().Truncate(FUNDING_INTERVAL).Add(FUNDING_INTERVAL)
+}
+
+// ChargeFee adds the commission of a fill at price, charged at rate, to Fees.
+func (p *Position) ChargeFee(price float64, rate float64) {
+	p.Fees += p.notional(price) * rate
+}
+
+// PayFunding adds the funding payment at price and rate to Funding: longs pay shorts when the rate
+// is positive, and shorts pay longs when it is negative.
+func (p *Position) PayFunding(price float64, rate float64) {
+	payment := p.notional(price) * rate
+	if p.Side == analysis.SELL {
+		payment = -payment
+	}
+
+	p.Funding += payment
+}
+
+// notional returns the value of the position (USDT) at price.
+func (p *Position) notional(price float64) float64 {
+	return p.Size * price / p.EntryPrice
+}
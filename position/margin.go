@@ -0,0 +1,40 @@
+package position
+
+import "hermes/analysis"
+
+// Margin types of Binance USD-M positions.
+const (
+	CROSSED  = "CROSSED"  // The whole wallet backs the position.
+	ISOLATED = "ISOLATED" // Only the position's margin backs it.
+)
+
+// MAINTENANCE_MARGIN_RATE is the maintenance margin rate of Binance USD-M's lowest notional bracket, used
+// to estimate liquidation prices.
+const MAINTENANCE_MARGIN_RATE = 0.004
+
+// MarginSettings holds the leverage and margin type positions of a symbol are opened with.
+type MarginSettings struct {
+	Leverage   int    `json:"leverage"`
+	MarginType string `json:"marginType"` // CROSSED, ISOLATED.
+}
+
+// SetMargin sets the position's leverage and margin type, and the Margin and Liquidation derived from them.
+func (p *Position) SetMargin(settings MarginSettings) {
+	p.Leverage, p.MarginType = settings.Leverage, settings.MarginType
+	p.Margin = p.Size / float64(p.Leverage)
+	p.Liquidation = p.estimateLiquidation()
+}
+
+// estimateLiquidation estimates the liquidation price of the position as if it were isolated, ignoring
+// fees and funding. NOTE: when CROSSED the rest of the wallet backs the position, so the actual
+// liquidation price is further away.
+func (p *Position) estimateLiquidation() float64 {
+	inverseLeverage := 1 / float64(p.Leverage)
+
+	liquidation := p.EntryPrice * (1 - inverseLeverage) / (1 - MAINTENANCE_MARGIN_RATE)
+	if p.Side == analysis.SELL {
+		liquidation = p.EntryPrice * (1 + inverseLeverage) / (1 + MAINTENANCE_MARGIN_RATE)
+	}
+
+	return analysis.Round(liquidation, p.Asset.PricePrecision)
+}
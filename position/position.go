@@ -1,73 +1,182 @@
 package position
 
 import (
+	"fmt"
 	"hermes/analysis"
-	"math"
+	"sync/atomic"
+	"time"
 )
 
 // TODO: move to a variable set elsewhere. Do not hardcode!
 const SL float64 = 0.04
 const TP float64 = 0.20
 
+var lastID int64 // ID of the last position created.
+
 type Position struct {
-	Asset       *analysis.Asset // Asset of the symbol.
-	EntryPrice  float64         // Entry price (USDT). When real, price returned by the exchange.
-	EntrySignal string          // "EMA Cross", "RSI". May be expanded in the future.
-	ExitPrice   float64         // Exit price (USDT). When real, price returned by the exchange.
-	ExitSignal  string          // "SL", "TP". May be an indicator in the future.
-	NetPNL      float64         // Net profit and loss (USDT).
-	PNL         float64         // Net profit and loss (percentage).
-	Quantity    float64         // Quantity of the position (in the base asset).
-	Real        bool            // Whether the position has been opened on an exchange as well.
-	Side        string          // analysis.BUY, analysis.SELL.
-	Size        float64         // Size of the position (USDT).
-	Symbol      string          // Name of the position's asset.
-	SL          float64         // Target stop loss (USDT).
-	TP          float64         // Target take profit (USDT).
+	Asset           *analysis.Asset     // Asset of the symbol.
+	ClosePending    string              // Exit signal of a closing order that failed and is being retried.
+	EntryPrice      float64             // Entry price (USDT). When real, price returned by the exchange.
+	EntrySignal     string              // "EMA Cross", "RSI". May be expanded in the future.
+	EntryTime       time.Time           // Time the position was opened.
+	ExitPrice       float64             // Exit price (USDT). When real, price returned by the exchange.
+	ExitSignal      string              // "SL", "TP". May be an indicator in the future.
+	ExitTime        time.Time           // Time the position was closed (zero while open).
+	Fees            float64             // Commissions paid on entry and exit (USDT).
+	Funding         float64             // Funding paid (positive) or received (negative) while open (USDT).
+	ID              int                 // Sequential ID of the position, unique within the session.
+	Indicators      analysis.Indicators // Snapshot of the indicators at entry.
+	InitialQuantity float64             // Quantity the position was opened with, before partial closes.
+	Leverage        int                 // Leverage the position is opened with.
+	Liquidation     float64             // Estimated liquidation price (USDT). 0 when it cannot be liquidated.
+	Margin          float64             // Margin locked by the position: Size / Leverage (USDT).
+	MarginType      string              // CROSSED, ISOLATED.
+	NetPNL          float64             // Net profit and loss, after fees and funding (USDT).
+	Partial         bool                // Whether the position is a portion split off by PartialClose.
+	PNL             float64             // Net profit and loss, after fees and funding (percentage of Size).
+	Quantity        float64             // Quantity of the position (in the base asset). Shrinks on partial closes.
+	Real            bool                // Whether the position has been opened on an exchange as well.
+	Side            string              // analysis.BUY, analysis.SELL.
+	Size            float64             // Size (notional) of the position (USDT). Shrinks on partial closes.
+	Symbol          string              // Name of the position's asset.
+	SL              float64             // Target stop loss (USDT).
+	TakeProfits     []TakeProfit        // Partial take-profit levels, nearest first (see SetTakeProfits).
+	TakeProfitsHit  int                 // Count of TakeProfits hit (i.e., index of the next level).
+	TP              float64             // Target take profit (USDT). Closes the rest of the position.
+	Trailing        float64             // Distance of the SL trailing the price once TakeProfits are hit (fraction).
 }
 
-// New creates a Position struct with all fields initialized.
-func New(a *analysis.Analysis, isReal bool, quantity float64, size float64) *Position {
+// New creates a Position struct with all fields initialized and the next ID, opened at entryTime.
+func New(a *analysis.Analysis, isReal bool, quantity float64, size float64, entryTime time.Time) *Position {
 	asset, price := a.Asset, a.Price
 
 	sl, tp := calculateSLAndTP(a)
 
 	p := &Position{
-		Asset:       asset,
-		EntryPrice:  price,
-		EntrySignal: a.EMACross + " EMA cross",
-		ExitPrice:   0.0,
-		ExitSignal:  "",
-		NetPNL:      0.0,
-		PNL:         0.0,
-		Real:        isReal,
-		Quantity:    round(quantity, asset.QuantityPrecision),
-		Side:        a.Side,
-		Size:        size,
-		Symbol:      a.Symbol,
-		SL:          sl,
-		TP:          tp,
+		Asset:           asset,
+		EntryPrice:      price,
+		EntrySignal:     a.EMACross + " EMA cross",
+		EntryTime:       entryTime,
+		ExitPrice:       0.0,
+		ExitSignal:      "",
+		ID:              int(atomic.AddInt64(&lastID, 1)),
+		Indicators:      a.Indicators(),
+		InitialQuantity: analysis.Round(quantity, asset.QuantityPrecision),
+		Leverage:        1,
+		Liquidation:     0.0,
+		Margin:          size,
+		MarginType:      ISOLATED,
+		NetPNL:          0.0,
+		PNL:             0.0,
+		Real:            isReal,
+		Quantity:        analysis.Round(quantity, asset.QuantityPrecision),
+		Side:            a.Side,
+		Size:            size,
+		Symbol:          a.Symbol,
+		SL:              sl,
+		TP:              tp,
 	}
 
 	return p
 }
 
-// Close closes a position by setting ExitPrice, ExitSignal, NetPNL, and PNL.
-func (p *Position) Close(exitPrice float64, exitSignal string) {
+// Close closes a position by setting ExitPrice, ExitSignal, ExitTime, NetPNL, and PNL, charging the
+// exit's commission at feeRate.
+func (p *Position) Close(exitPrice float64, exitSignal string, exitTime time.Time, feeRate float64) {
 	rawPNL := p.CalculatePNL(exitPrice)
 
-	p.ExitPrice, p.ExitSignal = exitPrice, exitSignal
-	p.NetPNL = rawPNL * p.Size
-	p.PNL = rawPNL * 100 // Store the percentage.
+	p.ExitPrice, p.ExitSignal, p.ExitTime = exitPrice, exitSignal, exitTime
+	p.ChargeFee(exitPrice, feeRate)
+	p.NetPNL = rawPNL*p.Size - p.Fees - p.Funding
+	p.PNL = p.NetPNL / p.Size * 100 // Store the percentage.
+}
+
+// PartialClose closes fraction (0 to 1) of the position's quantity at exitPrice and returns the closed
+// portion as a new, closed Position (Partial). Size, Quantity, Fees and Funding are split between the
+// portion and the position, which stays open with the rest, and so is Margin.
+func (p *Position) PartialClose(
+	fraction float64, exitPrice float64, exitSignal string, exitTime time.Time, feeRate float64,
+) *Position {
+	part := *p
+	part.Partial = true
+	part.Quantity = p.Asset.NormalizeQuantity(p.Quantity*fraction, analysis.MARKET_ORDER)
+
+	// Split by the quantity actually closed, which may be off from fraction due to rounding.
+	if p.Quantity > 0 {
+		fraction = part.Quantity / p.Quantity
+	}
+
+	part.Size, part.Margin = p.Size*fraction, p.Margin*fraction
+	part.Fees, part.Funding = p.Fees*fraction, p.Funding*fraction
+
+	p.Quantity = p.Asset.NormalizeQuantity(p.Quantity-part.Quantity, analysis.MARKET_ORDER)
+	p.Size, p.Margin = p.Size-part.Size, p.Margin-part.Margin
+	p.Fees, p.Funding = p.Fees-part.Fees, p.Funding-part.Funding
+
+	part.Close(exitPrice, exitSignal, exitTime, feeRate)
+
+	return &part
+}
+
+// Normalize rounds the position's quantity down to the asset's step size for orderType (shrinking Size
+// and Margin accordingly) and its SL and TP to the asset's tick size, and returns an error if its order
+// would be rejected by the asset's filters. Meant to be called before opening the position.
+func (p *Position) Normalize(orderType string) error {
+	if quantity := p.Asset.NormalizeQuantity(p.Quantity, orderType); quantity != p.Quantity {
+		p.Size, p.Quantity, p.InitialQuantity = p.Size*quantity/p.Quantity, quantity, quantity
+		p.SetMargin(MarginSettings{Leverage: p.Leverage, MarginType: p.MarginType})
+	}
+
+	p.SL, p.TP = p.Asset.NormalizePrice(p.SL), p.Asset.NormalizePrice(p.TP)
+
+	if err := p.Asset.ValidateOrder(p.Quantity, p.EntryPrice, orderType); err != nil {
+		return err
+	}
+
+	for _, price := range []float64{p.SL, p.TP} {
+		if err := p.Asset.ValidatePrice(price, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HoldingDuration returns how long the position has been held: until ExitTime if closed, until now otherwise.
+func (p *Position) HoldingDuration(now time.Time) time.Duration {
+	if !p.ExitTime.IsZero() {
+		now = p.ExitTime
+	}
+
+	return now.Sub(p.EntryTime)
+}
+
+// CheckTargets returns an error if SL and TP are not on the correct side of price for the position's side.
+func (p *Position) CheckTargets(price float64) error {
+	if p.Side == analysis.BUY && !(p.SL < price && price < p.TP) {
+		return fmt.Errorf("%s needs SL < %g < TP", p.Side, price)
+	}
+
+	if p.Side == analysis.SELL && !(p.TP < price && price < p.SL) {
+		return fmt.Errorf("%s needs TP < %g < SL", p.Side, price)
+	}
+
+	return nil
 }
 
-// CalculatePNL calculates then PNL based on the position's size and the [exit] price passed.
+// CalculatePNL calculates the raw PNL (fraction of Size, before fees and funding) at the [exit] price passed.
 func (p *Position) CalculatePNL(price float64) float64 {
 	if p.Side == analysis.BUY {
 		return (price - p.EntryPrice) / p.EntryPrice
 	}
 
-	return (p.EntryPrice - price) / price
+	return (p.EntryPrice - price) / p.EntryPrice
+}
+
+// UnrealizedPNL calculates the net PNL (USDT) of the open position at price: raw PNL minus the fees and
+// funding paid so far (i.e., before the exit's commission).
+func (p *Position) UnrealizedPNL(price float64) float64 {
+	return p.CalculatePNL(price)*p.Size - p.Fees - p.Funding
 }
 
 // calculateSLAndTP calculates the SL and TP targets based on the analysis' side, SL/TP constants, and price.
@@ -82,12 +191,5 @@ func calculateSLAndTP(a *analysis.Analysis) (float64, float64) {
 	// NOTE: may want to do math.Ceil or math.Floor according to SL/TP and BUY/SELL
 	// e.g., for BUY: SL should be Ceil (round up) and TP should be Floor (round down)
 
-	return round(sl, decimals), round(tp, decimals)
-}
-
-// round rounds price to decimals.
-func round(price float64, decimals int) float64 {
-	factor := math.Pow(10, float64(decimals))
-
-	return math.Round(price*factor) / factor
+	return analysis.Round(sl, decimals), analysis.Round(tp, decimals)
 }
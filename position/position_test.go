@@ -0,0 +1,188 @@
+package position
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"hermes/analysis"
+)
+
+const TAKER = 0.0005 // Taker fee rate of the tests.
+
+// newTestPosition returns a position of quantity on side entered at entryPrice with 1x leverage, after
+// charging the entry's commission at TAKER and paying funding.
+func newTestPosition(side string, entryPrice float64, quantity float64, funding float64) *Position {
+	p := &Position{
+		Asset:           &analysis.Asset{PricePrecision: 2, QuantityPrecision: 3, StepSize: 0.001},
+		EntryPrice:      entryPrice,
+		EntryTime:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		InitialQuantity: quantity,
+		Leverage:        1,
+		Margin:          entryPrice * quantity,
+		MarginType:      ISOLATED,
+		Quantity:        quantity,
+		Side:            side,
+		Size:            entryPrice * quantity,
+		Symbol:          "BTCUSDT",
+	}
+
+	p.ChargeFee(entryPrice, TAKER)
+	p.Funding = funding
+
+	return p
+}
+
+func almostEqual(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCalculatePNL(t *testing.T) {
+	tests := []struct {
+		name  string
+		side  string
+		price float64
+		want  float64
+	}{
+		{"long win", analysis.BUY, 110, 0.1},
+		{"long loss", analysis.BUY, 90, -0.1},
+		{"short win", analysis.SELL, 90, 0.1},
+		{"short loss", analysis.SELL, 110, -0.1},
+		{"flat", analysis.SELL, 100, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPosition(tt.side, 100, 1, 0)
+
+			if got := p.CalculatePNL(tt.price); !almostEqual(got, tt.want) {
+				t.Errorf("CalculatePNL(%g) = %g, want %g", tt.price, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	tests := []struct {
+		name       string
+		side       string
+		funding    float64
+		exitPrice  float64
+		wantFees   float64
+		wantNetPNL float64
+	}{
+		// Fees: 0.05 on entry (100 * TAKER) plus the exit's (exitPrice * TAKER).
+		{"long win", analysis.BUY, 0.1, 110, 0.105, 10 - 0.105 - 0.1},
+		{"long loss", analysis.BUY, 0.1, 90, 0.095, -10 - 0.095 - 0.1},
+		{"short win receiving funding", analysis.SELL, -0.1, 90, 0.095, 10 - 0.095 + 0.1},
+		{"short loss receiving funding", analysis.SELL, -0.1, 110, 0.105, -10 - 0.105 + 0.1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPosition(tt.side, 100, 1, tt.funding)
+			exitTime := p.EntryTime.Add(time.Hour)
+
+			p.Close(tt.exitPrice, "TP", exitTime, TAKER)
+
+			if p.ExitPrice != tt.exitPrice || p.ExitSignal != "TP" || !p.ExitTime.Equal(exitTime) {
+				t.Errorf("exit = (%g, %s, %s), want (%g, TP, %s)", p.ExitPrice, p.ExitSignal, p.ExitTime, tt.exitPrice, exitTime)
+			}
+
+			if !almostEqual(p.Fees, tt.wantFees) {
+				t.Errorf("Fees = %g, want %g", p.Fees, tt.wantFees)
+			}
+
+			if !almostEqual(p.NetPNL, tt.wantNetPNL) {
+				t.Errorf("NetPNL = %g, want %g", p.NetPNL, tt.wantNetPNL)
+			}
+
+			// PNL is a percentage of the size (100).
+			if !almostEqual(p.PNL, tt.wantNetPNL) {
+				t.Errorf("PNL = %g, want %g", p.PNL, tt.wantNetPNL)
+			}
+		})
+	}
+}
+
+func TestPartialClose(t *testing.T) {
+	tests := []struct {
+		name         string
+		side         string
+		fraction     float64
+		exitPrice    float64
+		wantQuantity float64 // Quantity of the portion closed.
+		wantNetPNL   float64 // Net PNL of the portion closed.
+	}{
+		// Position: 2 @ 100 (size 200), entry fees 0.1, funding 0.2. The portion takes its share of both.
+		{"long half win", analysis.BUY, 0.5, 110, 1, 10 - (0.05 + 0.055) - 0.1},
+		{"long quarter loss", analysis.BUY, 0.25, 90, 0.5, -5 - (0.025 + 0.0225) - 0.05},
+		{"short half win", analysis.SELL, 0.5, 90, 1, 10 - (0.05 + 0.045) - 0.1},
+		{"short quarter loss", analysis.SELL, 0.25, 110, 0.5, -5 - (0.025 + 0.0275) - 0.05},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPosition(tt.side, 100, 2, 0.2)
+			fees, funding := p.Fees, p.Funding
+
+			part := p.PartialClose(tt.fraction, tt.exitPrice, "TP1", p.EntryTime.Add(time.Hour), TAKER)
+
+			if !part.Partial || p.Partial {
+				t.Errorf("Partial = %t (part), %t (position), want true, false", part.Partial, p.Partial)
+			}
+
+			if !almostEqual(part.Quantity, tt.wantQuantity) || !almostEqual(p.Quantity, 2-tt.wantQuantity) {
+				t.Errorf("Quantity = %g (part), %g (rest), want %g, %g", part.Quantity, p.Quantity, tt.wantQuantity, 2-tt.wantQuantity)
+			}
+
+			if wantSize := tt.wantQuantity * 100; !almostEqual(part.Size, wantSize) || !almostEqual(p.Size, 200-wantSize) {
+				t.Errorf("Size = %g (part), %g (rest), want %g, %g", part.Size, p.Size, wantSize, 200-wantSize)
+			}
+
+			if !almostEqual(part.Margin+p.Margin, 200) {
+				t.Errorf("Margin = %g (part) + %g (rest), want 200", part.Margin, p.Margin)
+			}
+
+			// The rest keeps its share of the entry's fees and funding; the part adds the exit's fee.
+			exitFee := part.Quantity * tt.exitPrice * TAKER
+			if !almostEqual(part.Fees+p.Fees, fees+exitFee) || !almostEqual(part.Funding+p.Funding, funding) {
+				t.Errorf("Fees, Funding = %g, %g, want %g, %g", part.Fees+p.Fees, part.Funding+p.Funding, fees+exitFee, funding)
+			}
+
+			if !almostEqual(part.NetPNL, tt.wantNetPNL) {
+				t.Errorf("NetPNL = %g, want %g", part.NetPNL, tt.wantNetPNL)
+			}
+
+			if p.ExitPrice != 0 || p.NetPNL != 0 {
+				t.Errorf("rest closed: ExitPrice = %g, NetPNL = %g", p.ExitPrice, p.NetPNL)
+			}
+		})
+	}
+}
+
+func TestUnrealizedPNL(t *testing.T) {
+	tests := []struct {
+		name    string
+		side    string
+		funding float64
+		price   float64
+		want    float64
+	}{
+		// Entry fees: 0.05. The exit's commission is not deducted yet.
+		{"long win", analysis.BUY, 0.1, 105, 5 - 0.05 - 0.1},
+		{"long loss", analysis.BUY, 0.1, 95, -5 - 0.05 - 0.1},
+		{"short win receiving funding", analysis.SELL, -0.1, 95, 5 - 0.05 + 0.1},
+		{"short loss receiving funding", analysis.SELL, -0.1, 105, -5 - 0.05 + 0.1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPosition(tt.side, 100, 1, tt.funding)
+
+			if got := p.UnrealizedPNL(tt.price); !almostEqual(got, tt.want) {
+				t.Errorf("UnrealizedPNL(%g) = %g, want %g", tt.price, got, tt.want)
+			}
+		})
+	}
+}
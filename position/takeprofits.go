@@ -0,0 +1,81 @@
+package position
+
+import (
+	"math"
+
+	"hermes/analysis"
+)
+
+// TakeProfit is a level at which part of a position is closed.
+type TakeProfit struct {
+	Distance float64 // Distance from the entry price, as a fraction of it (e.g., 0.05 for +5%).
+	Fraction float64 // Fraction of the position's initial quantity to close.
+	Price    float64 // Price of the level (USDT), set by SetTakeProfits.
+}
+
+// SetTakeProfits sets the position's take-profit levels (nearest first) at their distances from the
+// entry price, and the distance (fraction of the price) of the SL trailing the rest of the position once
+// every level is hit (0 to disable). The rest is closed at TP if not stopped before.
+func (p *Position) SetTakeProfits(levels []TakeProfit, trailing float64) {
+	p.TakeProfits, p.TakeProfitsHit, p.Trailing = make([]TakeProfit, len(levels)), 0, trailing
+
+	for i, level := range levels {
+		level.Price = p.EntryPrice * (1 + level.Distance)
+		if p.Side == analysis.SELL {
+			level.Price = p.EntryPrice * (1 - level.Distance)
+		}
+
+		level.Price = p.Asset.NormalizePrice(level.Price)
+		p.TakeProfits[i] = level
+	}
+}
+
+// NextTakeProfit returns the position's next take-profit level, and whether price reaches it.
+func (p *Position) NextTakeProfit(price float64) (TakeProfit, bool) {
+	if p.TakeProfitsHit >= len(p.TakeProfits) {
+		return TakeProfit{}, false
+	}
+
+	level := p.TakeProfits[p.TakeProfitsHit]
+
+	return level, p.Side == analysis.BUY && price >= level.Price || p.Side == analysis.SELL && price <= level.Price
+}
+
+// TakeProfitQuantity returns the quantity closed by the take-profit level at price: its fraction of the
+// initial quantity, rounded down to the asset's step size of market orders. It returns the whole quantity
+// when the rest would break the asset's minimum quantity or notional, and 0 when the portion would (i.e.,
+// the level cannot be taken).
+func (p *Position) TakeProfitQuantity(level TakeProfit, price float64) float64 {
+	quantity := math.Min(p.InitialQuantity*level.Fraction, p.Quantity)
+	quantity = p.Asset.NormalizeQuantity(quantity, analysis.MARKET_ORDER)
+	rest := p.Asset.NormalizeQuantity(p.Quantity-quantity, analysis.MARKET_ORDER)
+
+	switch {
+	case rest <= 0 || p.Asset.ValidateOrder(rest, price, analysis.MARKET_ORDER) != nil:
+		return p.Quantity
+	case p.Asset.ValidateOrder(quantity, price, analysis.MARKET_ORDER) != nil:
+		return 0
+	}
+
+	return quantity
+}
+
+// Trail moves the SL up (BUY) or down (SELL) to Trailing away from price, once every take-profit level
+// is hit. The SL never moves back.
+func (p *Position) Trail(price float64) {
+	if p.Trailing == 0 || p.TakeProfitsHit < len(p.TakeProfits) {
+		return
+	}
+
+	if p.Side == analysis.BUY {
+		if sl := p.Asset.NormalizePrice(price * (1 - p.Trailing)); sl > p.SL {
+			p.SL = sl
+		}
+
+		return
+	}
+
+	if sl := p.Asset.NormalizePrice(price * (1 + p.Trailing)); sl < p.SL {
+		p.SL = sl
+	}
+}
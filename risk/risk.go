@@ -0,0 +1,192 @@
+package risk
+
+import (
+	"fmt"
+	"time"
+
+	"hermes/account"
+	"hermes/analysis"
+)
+
+// Breakers of the risk manager.
+const (
+	DAILY_LOSS     = "daily loss"         // Realized loss of the day exceeds Limits.MaxDailyLoss.
+	DRAWDOWN       = "drawdown"           // Drawdown from the equity peak exceeds Limits.MaxDrawdown.
+	LONG_EXPOSURE  = "long exposure"      // Net long exposure exceeds Limits.MaxExposure.
+	LOSSES         = "consecutive losses" // Consecutive losing trades reach Limits.MaxLosses.
+	SHORT_EXPOSURE = "short exposure"     // Net short exposure exceeds Limits.MaxExposure.
+)
+
+// breakers lists every breaker, in the order they are reported.
+var breakers = []string{DAILY_LOSS, DRAWDOWN, LOSSES, LONG_EXPOSURE, SHORT_EXPOSURE}
+
+// Limits holds the thresholds of the breakers. Zero values disable their breaker.
+type Limits struct {
+	MaxDailyLoss float64 // Realized loss of the day, as a fraction of the day's starting balance.
+	MaxDrawdown  float64 // Drawdown from the equity peak, as a fraction of the peak.
+	MaxExposure  float64 // Net notional in one direction, as a fraction of the equity.
+	MaxLosses    int     // Consecutive losing trades.
+}
+
+// Manager blocks new entries while any of its breakers is tripped. The daily loss and consecutive losses
+// breakers reset on the next day, the exposure ones as soon as the exposure falls below the limit, and
+// the drawdown one only on Reset.
+type Manager struct {
+	Limits
+	dayStart        time.Time       // Start of the current day.
+	dayStartBalance float64         // Account's total balance at dayStart.
+	equity          float64         // Account's equity on the last Update.
+	lossesFrom      int             // Index of the first closed position counted for consecutive losses.
+	longExposure    float64         // Net long notional (negative when net short) on the last Update.
+	peak            float64         // Highest equity since the last Reset.
+	tripped         map[string]bool // Tripped breakers.
+}
+
+// New creates a Manager with the given limits for an account starting with balance.
+func New(limits Limits, balance float64) *Manager {
+	return &Manager{
+		Limits:          limits,
+		dayStartBalance: balance,
+		equity:          balance,
+		peak:            balance,
+		tripped:         make(map[string]bool),
+	}
+}
+
+// Update evaluates the breakers on the account at the prices passed and time now, and returns the
+// breakers that tripped and the ones that reset since the last call.
+func (m *Manager) Update(
+	acct *account.Account, symbolPrices map[string]float64, now time.Time,
+) (tripped []string, reset []string) {
+	ledger := acct.Ledger(symbolPrices)
+
+	if dayStart := startOfDay(now); dayStart.After(m.dayStart) {
+		m.dayStart, m.dayStartBalance = dayStart, acct.TotalBalance
+		m.lossesFrom = len(acct.ClosedPositions)
+
+		reset = append(reset, m.release(DAILY_LOSS, LOSSES)...)
+	}
+
+	m.equity, m.longExposure = ledger.Equity, 0
+	if ledger.Equity > m.peak {
+		m.peak = ledger.Equity
+	}
+
+	for _, p := range acct.OpenPositions {
+		if p.Side == analysis.BUY {
+			m.longExposure += p.Size
+		} else {
+			m.longExposure -= p.Size
+		}
+	}
+
+	conditions := map[string]bool{
+		DAILY_LOSS: m.MaxDailyLoss > 0 &&
+			(m.dayStartBalance-acct.TotalBalance)/m.dayStartBalance >= m.MaxDailyLoss,
+		DRAWDOWN:       m.MaxDrawdown > 0 && (m.peak-ledger.Equity)/m.peak >= m.MaxDrawdown,
+		LONG_EXPOSURE:  m.MaxExposure > 0 && m.longExposure >= m.MaxExposure*ledger.Equity,
+		LOSSES:         m.MaxLosses > 0 && consecutiveLosses(acct, m.lossesFrom) >= m.MaxLosses,
+		SHORT_EXPOSURE: m.MaxExposure > 0 && -m.longExposure >= m.MaxExposure*ledger.Equity,
+	}
+
+	for _, breaker := range breakers {
+		if conditions[breaker] && !m.tripped[breaker] {
+			m.tripped[breaker] = true
+			tripped = append(tripped, breaker)
+		}
+	}
+
+	// Exposure breakers reset by themselves; the other ones are latched.
+	for _, breaker := range []string{LONG_EXPOSURE, SHORT_EXPOSURE} {
+		if !conditions[breaker] {
+			reset = append(reset, m.release(breaker)...)
+		}
+	}
+
+	return tripped, reset
+}
+
+// Allows returns an error naming the tripped breaker blocking a new position on side with size (notional,
+// USDT), if any. pendingLong is the net long notional of the orders not filled yet (negative when net
+// short), added to the exposure of the last Update.
+func (m *Manager) Allows(side string, size float64, pendingLong float64) error {
+	for _, breaker := range []string{DAILY_LOSS, DRAWDOWN, LOSSES} {
+		if m.tripped[breaker] {
+			return fmt.Errorf("risk breaker tripped: %s", breaker)
+		}
+	}
+
+	longExposure := m.longExposure + pendingLong
+
+	exposure, breaker := longExposure+size, LONG_EXPOSURE
+	if side == analysis.SELL {
+		exposure, breaker = -longExposure+size, SHORT_EXPOSURE
+	}
+
+	if m.MaxExposure > 0 && exposure > m.MaxExposure*m.equity {
+		return fmt.Errorf("risk breaker: %s would reach $%.2f (max: $%.2f)", breaker, exposure, m.MaxExposure*m.equity)
+	}
+
+	return nil
+}
+
+// Reset resets every breaker, restarts counting the day's loss, consecutive losses and the drawdown
+// from the current balances, and returns the breakers that were tripped.
+func (m *Manager) Reset(acct *account.Account) []string {
+	m.dayStartBalance, m.lossesFrom, m.peak = acct.TotalBalance, len(acct.ClosedPositions), m.equity
+
+	return m.release(breakers...)
+}
+
+// Tripped returns the tripped breakers.
+func (m *Manager) Tripped() []string {
+	var tripped []string
+
+	for _, breaker := range breakers {
+		if m.tripped[breaker] {
+			tripped = append(tripped, breaker)
+		}
+	}
+
+	return tripped
+}
+
+// release resets the breakers passed, returning the ones that were tripped.
+func (m *Manager) release(breakers ...string) []string {
+	var released []string
+
+	for _, breaker := range breakers {
+		if m.tripped[breaker] {
+			delete(m.tripped, breaker)
+			released = append(released, breaker)
+		}
+	}
+
+	return released
+}
+
+// consecutiveLosses counts the losing trades closed in a row since the closed position at index from, by
+// their net PNL with partial closes included. Partial closes are skipped.
+func consecutiveLosses(acct *account.Account, from int) int {
+	losses := 0
+
+	for i := len(acct.ClosedPositions) - 1; i >= from; i-- {
+		p := acct.ClosedPositions[i]
+		if p.Partial {
+			continue
+		}
+
+		if acct.TradePNLs[p.ID] > 0 {
+			break
+		}
+
+		losses += 1
+	}
+
+	return losses
+}
+
+// startOfDay returns midnight of t's day, in t's location.
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,80 @@
+package sizing
+
+import (
+	"fmt"
+	"math"
+
+	"hermes/analysis"
+)
+
+// Sizing modes.
+const (
+	ATR      = "atr"      // Risk Value of the balance on a stop ATR_MULTIPLIER ATRs away (volatility-scaled).
+	FIXED    = "fixed"    // Use Value USDT of margin.
+	FRACTION = "fraction" // Use Value (fraction) of the balance as margin.
+	RISK     = "risk"     // Risk Value (fraction) of the balance on hitting the SL.
+)
+
+const ATR_MULTIPLIER = 2.0 // ATRs between the entry and the stop assumed by the ATR mode.
+const ATR_PERIOD = 14      // Candles to average the true range over.
+
+// Sizing holds how the size of new positions is computed.
+type Sizing struct {
+	Mode  string  // ATR, FIXED, FRACTION, RISK.
+	Value float64 // Fraction of the balance (ATR, FRACTION, RISK) or USDT (FIXED).
+}
+
+// Validate returns an error if the mode is unknown or the value out of its bounds.
+func (s Sizing) Validate() error {
+	switch s.Mode {
+	case ATR, FRACTION, RISK:
+		if s.Value <= 0 || s.Value > 1 {
+			return fmt.Errorf("%s sizing needs a value in (0, 1], got %g", s.Mode, s.Value)
+		}
+	case FIXED:
+		if s.Value <= 0 {
+			return fmt.Errorf("%s sizing needs a positive value, got %g", s.Mode, s.Value)
+		}
+	default:
+		return fmt.Errorf("unknown sizing mode %q", s.Mode)
+	}
+
+	return nil
+}
+
+// Size returns the size (notional, USDT) of a new position given the balance, the leverage, the price,
+// the distance to the SL (fraction of the price), and the ATR of the symbol. Sizes are rounded down to
+// 2 digits.
+func (s Sizing) Size(balance float64, leverage float64, price float64, stopDistance float64, atr float64) float64 {
+	var size float64
+
+	switch s.Mode {
+	case ATR:
+		size = balance * s.Value / (ATR_MULTIPLIER * atr / price)
+	case FIXED:
+		size = s.Value * leverage
+	case FRACTION:
+		size = balance * s.Value * leverage
+	case RISK:
+		size = balance * s.Value / stopDistance
+	}
+
+	if math.IsInf(size, 0) || math.IsNaN(size) {
+		return 0
+	}
+
+	return math.Floor(size*100) / 100
+}
+
+// Bound caps quantity at the asset's maximum quantity for orderType (analysis.LIMIT_ORDER, MARKET_ORDER),
+// rounds it down to its step size, and returns an error if it breaks the asset's minimum quantity or
+// notional at price.
+func Bound(asset *analysis.Asset, quantity float64, price float64, orderType string) (float64, error) {
+	quantity = asset.NormalizeQuantity(quantity, orderType)
+
+	if err := asset.ValidateOrder(quantity, price, orderType); err != nil {
+		return 0, err
+	}
+
+	return quantity, nil
+}
@@ -0,0 +1,117 @@
+package telegram
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+)
+
+// Values for roles.
+const (
+	VIEWER = "viewer" // Can only run read-only commands (see viewerCommands).
+	TRADER = "trader" // Can run every command.
+)
+
+// viewerCommands are the commands and callback actions a VIEWER is allowed to run.
+var viewerCommands = map[string]bool{
+	"account":   true,
+	"chart":     true,
+	"config":    true,
+	"export":    true,
+	"help":      true,
+	"pnl":       true,
+	"positions": true,
+	"price":     true,
+	"start":     true,
+	"status":    true,
+	"ta":        true,
+	"upnl":      true,
+	REFRESH:     true,
+}
+
+var roles = make(map[int64]string) // Chat and user IDs authorised to use the bot, and their roles.
+
+// parseRoles parses "<ID>:<ROLE>,..." (e.g., "123:trader,-100456:viewer") into roles.
+func parseRoles(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		pair := strings.Split(entry, ":")
+		if len(pair) != 2 {
+			return fmt.Errorf("invalid entry %q (expected <ID>:<ROLE>)", entry)
+		}
+
+		id, err := strconv.ParseInt(pair[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid ID in %q: %w", entry, err)
+		}
+
+		role := strings.ToLower(pair[1])
+		if role != VIEWER && role != TRADER {
+			return fmt.Errorf("invalid role in %q (expected %s or %s)", entry, VIEWER, TRADER)
+		}
+
+		roles[id] = role
+	}
+
+	return nil
+}
+
+// roleOf returns the role of the user (if any) or of the chat, whichever grants more, or "" if
+// neither is authorised.
+func roleOf(user *tgbotapi.User, chat *tgbotapi.Chat) string {
+	userRole, chatRole := "", ""
+
+	if user != nil {
+		userRole = roles[user.ID]
+	}
+
+	if chat != nil {
+		chatRole = roles[chat.ID]
+	}
+
+	if userRole == TRADER || chatRole == "" {
+		return userRole
+	}
+
+	return chatRole
+}
+
+// isAllowed returns whether the role can run the command (or callback action).
+func isAllowed(role string, command string) bool {
+	return role == TRADER || role == VIEWER && viewerCommands[command]
+}
+
+// newAuditLog returns a logger appending to ./audit.log, which records who ran which command.
+func newAuditLog() (zerolog.Logger, error) {
+	file, err := os.OpenFile("./audit.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return zerolog.Logger{}, err
+	}
+
+	return zerolog.New(file).With().Timestamp().Logger(), nil
+}
+
+// audit records the command (or callback action) run by the user in the chat, and whether it was allowed.
+func (bot *Bot) audit(user *tgbotapi.User, chat *tgbotapi.Chat, role string, command string, allowed bool) {
+	event := bot.auditLog.Info()
+	if !allowed {
+		event = bot.auditLog.Warn()
+	}
+
+	if user != nil {
+		event = event.Int64("UserID", user.ID).Str("UserName", user.UserName)
+	}
+
+	if chat != nil {
+		event = event.Int64("ChatID", chat.ID)
+	}
+
+	event.Str("Role", role).Bool("Allowed", allowed).Msg(command)
+}
@@ -0,0 +1,86 @@
+package telegram
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"hermes/account"
+	"hermes/position"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MAX_CAPTION_LENGTH is the maximum length of a Telegram photo's caption.
+const MAX_CAPTION_LENGTH = 1024
+
+// ChartRenderer renders a PNG chart of symbol, with the levels of p if not nil.
+type ChartRenderer func(symbol string, p *position.Position) ([]byte, error)
+
+// EnableCharts attaches charts rendered by render to signals and new positions, and enables /chart.
+// It should be called before sending any message.
+func (bot *Bot) EnableCharts(render ChartRenderer) {
+	bot.renderChart = render
+}
+
+// attachChart returns the message as a photo of symbol's chart (with the levels of p if not nil)
+// captioned by its text, or the message itself if charts are disabled or rendering fails.
+func (bot *Bot) attachChart(message tgbotapi.MessageConfig, symbol string, p *position.Position) tgbotapi.Chattable {
+	if bot.renderChart == nil || len(message.Text) > MAX_CAPTION_LENGTH {
+		return message
+	}
+
+	png, err := bot.renderChart(symbol, p)
+	if err != nil {
+		bot.Warn().Str("err", err.Error()).Str("symbol", symbol).Msg("Could not render chart")
+		return message
+	}
+
+	photo := tgbotapi.NewPhoto(message.ChatID, tgbotapi.FileBytes{Name: symbol + ".png", Bytes: png})
+	photo.Caption = message.Text
+	photo.ParseMode = message.ParseMode
+	photo.ReplyMarkup = message.ReplyMarkup
+	photo.ReplyToMessageID = message.ReplyToMessageID
+
+	return photo
+}
+
+// reportChart replies with the chart of the position (ID) or symbol passed to /chart, including the
+// levels of the position (the symbol's oldest open position, if any, when a symbol is passed).
+func (bot *Bot) reportChart(acct *account.Account, update tgbotapi.Update) {
+	arg := strings.TrimSpace(update.Message.CommandArguments())
+	if arg == "" {
+		bot.report("🤷 Usage: /chart 3|BTC", update)
+		return
+	}
+
+	symbol := parseSymbol(arg)
+	p := findOpenPosition(acct, symbol)
+
+	if _, err := strconv.Atoi(strings.TrimPrefix(arg, "#")); err == nil {
+		if p, err = parsePosition(acct, arg); err != nil {
+			bot.report("🧘‍♂️ "+err.Error(), update)
+			return
+		}
+
+		symbol = p.Symbol
+	}
+
+	if bot.renderChart == nil {
+		bot.report("🤷 Charts are disabled", update)
+		return
+	}
+
+	png, err := bot.renderChart(symbol, p)
+	if err != nil {
+		bot.report("🤷 "+err.Error(), update)
+		return
+	}
+
+	photo := tgbotapi.NewPhoto(update.Message.Chat.ID, tgbotapi.FileBytes{Name: symbol + ".png", Bytes: png})
+	photo.Caption = fmt.Sprintf("📈 *%s*", symbol)
+	photo.ParseMode = tgbotapi.ModeMarkdown
+	photo.ReplyToMessageID = update.Message.MessageID
+
+	bot.enqueue(outboundMessage{chattable: photo})
+}
@@ -0,0 +1,226 @@
+package telegram
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"hermes/analysis"
+	"hermes/position"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Callback data is formatted as "<action>[:<position ID>]" or "<action>:<symbol>:<side>" (OPEN), at most
+// 64 bytes.
+const (
+	BREAKEVEN = "breakeven" // Move the SL of the position to its entry price.
+	CANCEL    = "cancel"    // Dismiss a confirmation.
+	CLOSE     = "close"     // Close the position (confirmed).
+	CLOSE_ALL = "closeall"  // Close all open positions (confirmed).
+	EXIT      = "exit"      // Ask for confirmation before closing the position.
+	OPEN      = "open"      // Open a position on the signal's symbol and side.
+	REFRESH   = "refresh"   // Update the position message with its unrealized PNL.
+)
+
+// buildSignalKeyboard returns a keyboard with a button to open a position on the signal.
+func buildSignalKeyboard(a *analysis.Analysis) *tgbotapi.InlineKeyboardMarkup {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s Open %s", analysis.Emojis[a.Side], a.Side),
+				strings.Join([]string{OPEN, a.Symbol, a.Side}, ":"),
+			),
+		),
+	)
+
+	return &keyboard
+}
+
+// buildPositionKeyboard returns a keyboard with buttons to manage the open position.
+func buildPositionKeyboard(p *position.Position) *tgbotapi.InlineKeyboardMarkup {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Close", positionData(EXIT, p)),
+			tgbotapi.NewInlineKeyboardButtonData("🛡 Move SL to breakeven", positionData(BREAKEVEN, p)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Refresh PNL", positionData(REFRESH, p)),
+		),
+	)
+
+	return &keyboard
+}
+
+// positionData returns the callback data of action on the position.
+func positionData(action string, p *position.Position) string {
+	return action + ":" + strconv.Itoa(p.ID)
+}
+
+// confirm replies to the message with content and an inline keyboard to either go ahead with data
+// or cancel.
+func (bot *Bot) confirm(content string, data string, replyTo *tgbotapi.Message) {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Confirm", data),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Cancel", CANCEL),
+		),
+	)
+
+	msg := newMessage(replyTo.Chat.ID, content, &keyboard)
+	msg.ReplyToMessageID = replyTo.MessageID
+
+	bot.enqueue(outboundMessage{chattable: msg})
+}
+
+// handleCallback acts on an inline keyboard button press, answering it with a short notice.
+func (bot *Bot) handleCallback(query *tgbotapi.CallbackQuery, engine Engine) {
+	message := query.Message
+	if message == nil {
+		return
+	}
+
+	role := roleOf(query.From, message.Chat)
+	if role == "" {
+		bot.Error().Int64("ID", query.From.ID).Str("Name", query.From.FirstName).Msg("⛔️ Unauthorised access")
+		return
+	}
+
+	bot.Info().Str("data", query.Data).Str("UserName", query.From.UserName).Str("role", role).Msg("📡 Got callback")
+
+	args := strings.Split(query.Data, ":")
+	action, target := args[0], ""
+	if len(args) >= 2 {
+		target = args[1]
+	}
+
+	acct, symbolPrices := engine.Snapshot()
+
+	// Actions on a position pass its ID (left nil if it is not open anymore).
+	var p *position.Position
+	if id, err := strconv.Atoi(target); err == nil {
+		p = acct.FindOpenPosition(id)
+	}
+
+	allowed := isAllowed(role, action)
+	bot.audit(query.From, message.Chat, role, query.Data, allowed)
+
+	notice := ""
+
+	if !allowed {
+		action, notice = "", fmt.Sprintf("⛔️ Requires the %s role", TRADER)
+	}
+
+	switch action {
+	case BREAKEVEN:
+		if p == nil {
+			notice = "No open position #" + target
+			bot.editKeyboard(message, nil)
+			break
+		}
+
+		p, err := engine.UpdateTargets(p.ID, p.EntryPrice, p.TP)
+		if err != nil {
+			notice = err.Error()
+			break
+		}
+
+		notice = "SL moved to breakeven"
+		bot.editMessage(message, buildNewPositionReport(p), buildPositionKeyboard(p))
+	case CANCEL:
+		bot.editMessage(message, "👌 Cancelled", nil)
+	case CLOSE:
+		content := "🤷 No open position #" + target // E.g., confirmed twice, or hit its SL/TP meanwhile.
+
+		if p != nil {
+			if closed, err := engine.ClosePosition(p.ID, "MANUAL"); err != nil {
+				content = "🤷 " + err.Error()
+			} else {
+				content = buildClosedPositionReport(closed)
+			}
+		}
+
+		bot.editMessage(message, content, nil)
+	case CLOSE_ALL:
+		closedPositions := engine.CloseAllPositions("MANUAL")
+
+		netPNL := 0.0
+		for _, p := range closedPositions {
+			netPNL += p.NetPNL
+		}
+
+		bot.editMessage(message, fmt.Sprintf(
+			"%s Closed *%d* positions\n\n    💰 PNL: *$%.2f*",
+			GetPNLEmoji(netPNL), len(closedPositions), netPNL,
+		), nil)
+	case EXIT:
+		if p == nil {
+			notice = "No open position #" + target
+			bot.editKeyboard(message, nil)
+			break
+		}
+
+		bot.confirm(buildCloseConfirmation(p, symbolPrices[p.Symbol]), positionData(CLOSE, p), message)
+	case OPEN:
+		if len(args) != 3 {
+			break
+		}
+
+		if _, err := engine.OpenPosition(target, args[2], 0, 0, 0); err != nil {
+			notice = err.Error()
+			break
+		}
+
+		notice = "Opened " + target
+		bot.editKeyboard(message, nil)
+	case REFRESH:
+		if p == nil {
+			notice = "No open position #" + target
+			bot.editKeyboard(message, nil)
+			break
+		}
+
+		price := symbolPrices[p.Symbol]
+		pnl := p.UnrealizedPNL(price)
+
+		bot.editMessage(message, fmt.Sprintf(
+			"%s\n    %s uPNL: *$%.2f* (%.2f%%) @ %g\n    🕰 %s",
+			buildNewPositionReport(p), GetPNLEmoji(pnl), pnl, pnl/p.Size*100, price,
+			time.Now().Format("15:04:05"),
+		), buildPositionKeyboard(p))
+	}
+
+	if _, err := bot.Request(tgbotapi.NewCallback(query.ID, notice)); err != nil {
+		bot.Error().Str("err", err.Error()).Msg("Could not answer callback")
+	}
+}
+
+// editMessage queues replacing the text and inline keyboard of the message (removing it if keyboard is nil).
+func (bot *Bot) editMessage(message *tgbotapi.Message, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	if len(message.Photo) >= 1 { // Messages with a chart have a caption instead of a text.
+		edit := tgbotapi.NewEditMessageCaption(message.Chat.ID, message.MessageID, text)
+		edit.ParseMode = tgbotapi.ModeMarkdown
+		edit.ReplyMarkup = keyboard
+
+		bot.enqueue(outboundMessage{chattable: edit})
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, text)
+	edit.ParseMode = tgbotapi.ModeMarkdown
+	edit.ReplyMarkup = keyboard
+
+	bot.enqueue(outboundMessage{chattable: edit})
+}
+
+// editKeyboard queues replacing the inline keyboard of the message (removing it if keyboard is nil).
+func (bot *Bot) editKeyboard(message *tgbotapi.Message, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	if keyboard == nil {
+		keyboard = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	}
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(message.Chat.ID, message.MessageID, *keyboard)
+
+	bot.enqueue(outboundMessage{chattable: edit})
+}
@@ -0,0 +1,188 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"hermes/position"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const MAX_MESSAGE_LENGTH = 4096             // Maximum length of a Telegram message's text.
+const MAX_SEND_ATTEMPTS = 5                 // Attempts to deliver a message before dropping it.
+const QUEUE_SIZE = 256                      // Messages waiting to be sent before dropping new ones.
+const SIGNAL_BATCH_WINDOW = 3 * time.Second // Time to wait for more signals before sending a digest.
+const FLUSH_TIMEOUT = 10 * time.Second      // Maximum time to wait for the queue to be sent on Flush.
+
+// outboundMessage is an entry of the outbound queue: either a Chattable to deliver, a signal to
+// batch into a digest, or a flush marker closed once all the previous entries have been sent.
+// Messages with a symbol get its chart attached (rendered by the sender, see attachChart).
+type outboundMessage struct {
+	chattable tgbotapi.Chattable
+	flushed   chan struct{}
+	keyboard  *tgbotapi.InlineKeyboardMarkup // Signal's keyboard (nil if none).
+	position  *position.Position             // Position whose levels are drawn on the chart.
+	signal    string                         // Signal's text.
+	symbol    string                         // Symbol of the chart to attach.
+}
+
+// enqueue adds the message to the outbound queue, dropping it if the queue is full so that callers
+// (e.g., the WebSocket handler) never block on Telegram.
+func (bot *Bot) enqueue(message outboundMessage) {
+	select {
+	case bot.queue <- message:
+	default:
+		bot.Error().Int("size", QUEUE_SIZE).Msg("Dropped Telegram message: queue is full")
+	}
+}
+
+// Flush waits until all the messages queued so far have been sent or FLUSH_TIMEOUT has elapsed.
+func (bot *Bot) Flush() {
+	flushed := make(chan struct{})
+
+	bot.enqueue(outboundMessage{flushed: flushed})
+
+	select {
+	case <-flushed:
+	case <-time.After(FLUSH_TIMEOUT):
+		bot.Warn().Msg("Timed out flushing Telegram messages")
+	}
+}
+
+// processQueue is the single sender of the bot: it delivers the queued messages in order, batching
+// the signals received within SIGNAL_BATCH_WINDOW into digests. A message queued after a signal (e.g.,
+// the position it opened, or a flush) closes the batch: it is held until the digest is sent.
+func (bot *Bot) processQueue() {
+	for message := range bot.queue {
+		if message.signal == "" {
+			bot.process(message)
+			continue
+		}
+
+		signals := []outboundMessage{message}
+		timeout := time.After(SIGNAL_BATCH_WINDOW)
+
+	batching:
+		for {
+			select {
+			case next := <-bot.queue:
+				if next.signal != "" {
+					signals = append(signals, next)
+					continue
+				}
+
+				bot.sendDigests(signals)
+				signals = nil
+				bot.process(next)
+
+				break batching
+			case <-timeout:
+				break batching
+			}
+		}
+
+		bot.sendDigests(signals)
+	}
+}
+
+// process delivers a non-signal message or acknowledges a flush.
+func (bot *Bot) process(message outboundMessage) {
+	if message.flushed != nil {
+		close(message.flushed)
+		return
+	}
+
+	if config, isMessage := message.chattable.(tgbotapi.MessageConfig); isMessage && message.symbol != "" {
+		bot.deliver(bot.attachChart(config, message.symbol, message.position))
+		return
+	}
+
+	bot.deliver(message.chattable)
+}
+
+// sendDigests sends the signals passed, merging them into as few messages as Telegram allows.
+func (bot *Bot) sendDigests(signals []outboundMessage) {
+	switch len(signals) {
+	case 0:
+		return
+	case 1: // NOTE: only single signals get a chart, digests would take too long to render.
+		bot.deliver(bot.attachChart(
+			newMessage(signalsChatID, signals[0].signal, signals[0].keyboard), signals[0].symbol, nil,
+		))
+		return
+	}
+
+	header := fmt.Sprintf("⚡️ *%d signals*\n\n", len(signals))
+	texts := []string{}
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	flush := func() {
+		var keyboard *tgbotapi.InlineKeyboardMarkup
+		if len(rows) >= 1 {
+			keyboard = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+		}
+
+		bot.deliver(newMessage(signalsChatID, header+strings.Join(texts, "\n\n"), keyboard))
+
+		texts, rows = []string{}, nil
+	}
+
+	length := len(header)
+
+	for _, signal := range signals {
+		if len(texts) >= 1 && length+len(signal.signal)+2 > MAX_MESSAGE_LENGTH {
+			flush()
+			length = len(header)
+		}
+
+		texts = append(texts, signal.signal)
+		length += len(signal.signal) + 2
+
+		if signal.keyboard != nil {
+			rows = append(rows, signal.keyboard.InlineKeyboard...)
+		}
+	}
+
+	flush()
+}
+
+// deliver sends the Chattable, waiting and retrying when Telegram asks to (i.e., "Too Many
+// Requests: retry after N"). Errors are logged and never terminate the process.
+func (bot *Bot) deliver(chattable tgbotapi.Chattable) {
+	for attempt := 1; attempt <= MAX_SEND_ATTEMPTS; attempt++ {
+		_, err := bot.Request(chattable)
+		if err == nil {
+			return
+		}
+
+		var apiErr *tgbotapi.Error
+		if !errors.As(err, &apiErr) || apiErr.RetryAfter == 0 {
+			bot.Error().Str("err", err.Error()).Msg("Could not send Telegram message")
+			return
+		}
+
+		bot.Warn().
+			Int("attempt", attempt).
+			Int("retryAfter", apiErr.RetryAfter).
+			Msg("Rate limited by Telegram")
+
+		time.Sleep(time.Duration(apiErr.RetryAfter) * time.Second)
+	}
+
+	bot.Error().Int("attempts", MAX_SEND_ATTEMPTS).Msg("Dropped Telegram message: rate limited")
+}
+
+// newMessage returns a Markdown message to the chat with an inline keyboard (none if keyboard is nil).
+func newMessage(chat int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup) tgbotapi.MessageConfig {
+	message := tgbotapi.NewMessage(chat, text)
+	message.ParseMode = tgbotapi.ModeMarkdown
+
+	if keyboard != nil {
+		message.ReplyMarkup = keyboard
+	}
+
+	return message
+}
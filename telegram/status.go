@@ -0,0 +1,118 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Status is a snapshot of the engine's health, reported by /status.
+type Status struct {
+	Breakers       []string  // Tripped risk breakers.
+	LastKline      time.Time // Time the last kline was received.
+	Mode           string    // Engine's mode (e.g., "active", "paused").
+	PendingEntries int       // Count of limit entries waiting to be filled.
+	SendsSignals   bool      // Whether signals are sent.
+	StartedAt      time.Time // Time the session started.
+	Symbols        int       // Count of symbols streamed.
+}
+
+// STALE_STREAM is the time without klines after which the WebSocket stream is reported as unhealthy.
+const STALE_STREAM = time.Minute
+
+// commands lists every command with its description, for /help and the Telegram clients' menu.
+var commands = []tgbotapi.BotCommand{
+	{Command: "account", Description: "Breakdown of the trading account"},
+	{Command: "breakeven", Description: "ID|SYMBOL: move the SL to the entry price"},
+	{Command: "chart", Description: "ID|SYMBOL: candlestick chart with EMAs, RSI, and position levels"},
+	{Command: "close", Description: "ID|SYMBOL: close the open position"},
+	{Command: "closeall", Description: "Close all open positions"},
+	{Command: "config", Description: "Effective settings"},
+	{Command: "export", Description: "Trade journal (CSV and JSON Lines)"},
+	{Command: "help", Description: "List of commands"},
+	{Command: "open", Description: "SYMBOL BUY|SELL [size] [sl] [tp]: open a manual position"},
+	{Command: "panic", Description: "Close all open positions and stop trading"},
+	{Command: "pause", Description: "Stop opening new positions"},
+	{Command: "pnl", Description: "Net PNL (closed positions)"},
+	{Command: "positions", Description: "ID and unrealized PNL of each open position"},
+	{Command: "price", Description: "SYMBOL: last price"},
+	{Command: "resume", Description: "Go back to opening new positions and reset risk breakers"},
+	{Command: "signals", Description: "on|off: turn sending signals on or off"},
+	{Command: "sl", Description: "ID|SYMBOL PRICE: move the SL"},
+	{Command: "status", Description: "Uptime, WebSocket health, and mode"},
+	{Command: "ta", Description: "SYMBOL: trend, RSI, EMAs, and active cross"},
+	{Command: "tp", Description: "ID|SYMBOL PRICE: move the TP"},
+	{Command: "upnl", Description: "Unrealized PNL (open positions)"},
+}
+
+// registerCommands sets the commands shown by the Telegram clients' menu.
+func (bot *Bot) registerCommands() {
+	if _, err := bot.Request(tgbotapi.NewSetMyCommands(commands...)); err != nil {
+		bot.Error().Str("err", err.Error()).Msg("Could not register commands")
+	}
+}
+
+// reportHelp replies with the commands the role is allowed to run.
+func (bot *Bot) reportHelp(role string, update tgbotapi.Update) {
+	content := fmt.Sprintf("📖 Commands (role: *%s*)\n\n", role)
+
+	for _, command := range commands {
+		if isAllowed(role, command.Command) {
+			content += fmt.Sprintf("/%s: %s\n", command.Command, command.Description)
+		}
+	}
+
+	bot.report(content, update)
+}
+
+func (bot *Bot) reportStatus(engine Engine, update tgbotapi.Update) {
+	status := engine.Status()
+	sinceLastKline := time.Since(status.LastKline)
+
+	streamHealth := "🟢 healthy"
+	if status.LastKline.IsZero() || sinceLastKline > STALE_STREAM {
+		streamHealth = "🔴 stale"
+	}
+
+	risk := "🟢 no breaker tripped"
+	if len(status.Breakers) > 0 {
+		risk = "🛑 tripped: " + strings.Join(status.Breakers, ", ")
+	}
+
+	bot.report(fmt.Sprintf(
+		"🩺 *STATUS*\n\n"+
+			"    ⏳ Uptime: %s\n"+
+			"    🔌 WebSocket: %s (last kline %s ago)\n"+
+			"    🪙 Symbols: %d\n"+
+			"    🚦 Mode: *%s* (signals: %t)\n"+
+			"    📥 Pending entries: %d\n"+
+			"    🛡 Risk: %s",
+		time.Since(status.StartedAt).Round(time.Second),
+		streamHealth, sinceLastKline.Round(time.Second),
+		status.Symbols,
+		status.Mode, status.SendsSignals,
+		status.PendingEntries,
+		risk,
+	), update)
+}
+
+func (bot *Bot) reportConfig(engine Engine, update tgbotapi.Update) {
+	settings := engine.Settings()
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	content := "⚙️ *CONFIG*\n\n"
+	for _, key := range keys {
+		content += fmt.Sprintf("    %s: `%s`\n", key, settings[key])
+	}
+
+	bot.report(content, update)
+}
@@ -2,8 +2,11 @@ package telegram
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"hermes/account"
 	"hermes/analysis"
@@ -17,47 +20,113 @@ import (
 type Bot struct {
 	*tgbotapi.BotAPI
 	*zerolog.Logger
+	auditLog    zerolog.Logger       // Records who ran which command (see audit).
+	queue       chan outboundMessage // Outbound messages, sent by a single goroutine (see processQueue).
+	renderChart ChartRenderer        // Renders charts (nil if disabled, see EnableCharts).
 }
 
-var chatID int64
+// Engine is implemented by the trading engine so that commands can act on its state.
+type Engine interface {
+	Analyze(symbol string) (*analysis.Analysis, error)
+	OpenPosition(symbol string, side string, size float64, sl float64, tp float64) (*position.Position, error)
+	ClosePosition(id int, exitSignal string) (*position.Position, error)
+	CloseAllPositions(exitSignal string) []*position.Position
+	JournalFiles() []string
+	Mode() (string, bool)
+	Pause()
+	Panic() []*position.Position
+	Price(symbol string) (float64, error)
+	Resume()
+	SetSignals(on bool)
+	Settings() map[string]string
+	Snapshot() (account.Account, map[string]float64) // Copies of the account and the symbols' last prices.
+	Status() Status
+	UpdateTargets(id int, sl float64, tp float64) (*position.Position, error)
+}
+
+var chatID int64          // Main chat: receives every notification but signals and position events.
+var positionsChatID int64 // Receives position events. Defaults to chatID.
+var signalsChatID int64   // Receives signals and alerts (e.g., a channel). Defaults to chatID.
 
-func New(log *zerolog.Logger, onDev bool) Bot {
+// New creates a Bot for the DEV_ or PROD_ Telegram credentials, returning an error if they are
+// missing or the Telegram API is unreachable.
+func New(log *zerolog.Logger, onDev bool) (*Bot, error) {
 	prefix := "PROD_"
 	if onDev {
 		prefix = "DEV_"
 	}
 
-	bot, err := tgbotapi.NewBotAPI(os.Getenv(prefix + "TELEGRAM_APITOKEN"))
+	token := os.Getenv(prefix + "TELEGRAM_APITOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("%sTELEGRAM_APITOKEN is not set", prefix)
+	}
+
+	bot, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
-		log.Fatal().Str("err", err.Error()).Msg("Crashed creating Telegram bot")
+		return nil, fmt.Errorf("could not create Telegram bot: %w", err)
 	}
 
 	chatID, err = strconv.ParseInt(os.Getenv(prefix+"TELEGRAM_CHATID"), 10, 64)
 	if err != nil {
-		log.Fatal().Str("err", err.Error()).Msg("Crashed parsing " + prefix + "TELEGRAM_CHATID")
+		return nil, fmt.Errorf("could not parse %sTELEGRAM_CHATID: %w", prefix, err)
+	}
+
+	positionsChatID, signalsChatID = chatID, chatID
+
+	for key, id := range map[string]*int64{"POSITIONS_CHATID": &positionsChatID, "SIGNALS_CHATID": &signalsChatID} {
+		if value := os.Getenv(prefix + "TELEGRAM_" + key); value != "" {
+			if *id, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, fmt.Errorf("could not parse %sTELEGRAM_%s: %w", prefix, key, err)
+			}
+		}
+	}
+
+	roles[chatID] = TRADER
+
+	if err := parseRoles(os.Getenv(prefix + "TELEGRAM_USERS")); err != nil {
+		return nil, fmt.Errorf("could not parse %sTELEGRAM_USERS: %w", prefix, err)
+	}
+
+	auditLog, err := newAuditLog()
+	if err != nil {
+		return nil, fmt.Errorf("could not create the audit log: %w", err)
 	}
 
-	return Bot{bot, log}
+	b := &Bot{BotAPI: bot, Logger: log, auditLog: auditLog, queue: make(chan outboundMessage, QUEUE_SIZE)}
+
+	go b.processQueue()
+
+	return b, nil
 }
 
-func (bot *Bot) Listen(acct *account.Account, symbolPrices map[string]float64) {
+// Listen handles the commands and inline keyboard button presses sent to the bot. The account and
+// prices they read are snapshots taken from the engine, as the engine keeps updating them meanwhile.
+func (bot *Bot) Listen(engine Engine) {
 	updateConfig := tgbotapi.NewUpdate(0)
 	updateConfig.Timeout = 30
 
+	bot.registerCommands()
+
 	updates := bot.GetUpdatesChan(updateConfig)
 
-	bot.Info().Int64("chatID", chatID).Msg("📡 Listening for commands")
+	bot.Info().Int64("chatID", chatID).Int("authorised", len(roles)).Msg("📡 Listening for commands")
 
 	for update := range updates {
 		message := update.Message
 
-		if update.Message == nil { // Ignore any non-Message updates
+		if update.CallbackQuery != nil {
+			bot.handleCallback(update.CallbackQuery, engine)
 			continue
 		}
 
-		chat := message.Chat
+		if update.Message == nil { // Ignore any non-Message and non-CallbackQuery updates
+			continue
+		}
+
+		chat, user := message.Chat, message.From
+		role := roleOf(user, chat)
 
-		if chat.ID != chatID { // Make it private: ignore messages not coming from our chatID.
+		if role == "" { // Make it private: ignore messages not coming from authorised chats or users.
 			bot.Error().
 				Int64("ID", chat.ID).
 				Str("Name", chat.FirstName).
@@ -69,38 +138,72 @@ func (bot *Bot) Listen(acct *account.Account, symbolPrices map[string]float64) {
 			continue
 		}
 
-		bot.Info().Str("text", message.Text).Str("UserName", chat.UserName).Msg("📡 Got command")
+		bot.Info().Str("text", message.Text).Str("UserName", chat.UserName).Str("role", role).Msg("📡 Got command")
+
+		allowed := isAllowed(role, message.Command())
+		bot.audit(user, chat, role, message.Text, allowed)
+
+		if !allowed {
+			bot.report(fmt.Sprintf("⛔️ /%s requires the *%s* role", message.Command(), TRADER), update)
+			continue
+		}
+
+		acct, symbolPrices := engine.Snapshot()
 
 		switch message.Command() {
 		case "account":
-			bot.reportAccount(acct, symbolPrices, update)
+			bot.reportAccount(&acct, symbolPrices, engine, update)
+		case "chart":
+			bot.reportChart(&acct, update)
+		case "breakeven", "sl", "tp":
+			bot.updateTargets(&acct, engine, update)
+		case "close":
+			bot.confirmClose(&acct, symbolPrices, update)
+		case "closeall":
+			bot.confirmCloseAll(&acct, update)
+		case "config":
+			bot.reportConfig(engine, update)
+		case "export":
+			bot.exportJournal(engine, update)
+		case "help", "start":
+			bot.reportHelp(role, update)
+		case "open":
+			bot.openPosition(engine, update)
+		case "panic":
+			bot.haltTrading(engine)
+		case "pause":
+			engine.Pause()
+			bot.SendMessage("⏸ *PAUSED*: managing open positions, not opening new ones")
 		case "pnl":
-			bot.reportNetPNL(acct, update)
+			bot.reportNetPNL(&acct, update)
 		case "positions":
-			bot.reportOpenPositions(acct, symbolPrices, update)
+			bot.reportOpenPositions(&acct, symbolPrices, update)
+		case "price":
+			bot.reportPrice(engine, update)
+		case "resume":
+			engine.Resume()
+			bot.SendMessage("▶️ *RESUMED*: opening new positions")
+		case "signals":
+			bot.toggleSignals(engine, update)
+		case "status":
+			bot.reportStatus(engine, update)
+		case "ta":
+			bot.reportAnalysis(engine, update)
 		case "upnl":
-			bot.reportUnrealizedPNL(acct, symbolPrices, update)
+			bot.reportUnrealizedPNL(&acct, symbolPrices, update)
+		default:
+			bot.report(fmt.Sprintf("🤷 Unknown command /%s. See /help", message.Command()), update)
 		}
 	}
 }
 
 func (bot *Bot) SendMessage(text string) {
-	message := tgbotapi.MessageConfig{
-		BaseChat: tgbotapi.BaseChat{
-			ChatID: chatID,
-		},
-		Text:      text,
-		ParseMode: tgbotapi.ModeMarkdown,
-	}
+	bot.sendMessageTo(chatID, text, nil)
+}
 
-	// NOTE: may want to continue running instead of doing os.Exit()
-	// TODO: handle err="Too Many Requests: retry after 39" without exiting
-	if _, err := bot.Send(message); err != nil {
-		bot.Fatal().
-			Str("err", err.Error()).
-			Str("text", text).
-			Msg("Crashed sending Telegram message")
-	}
+// sendMessageTo queues text to the chat with an inline keyboard under it (none if keyboard is nil).
+func (bot *Bot) sendMessageTo(chat int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	bot.enqueue(outboundMessage{chattable: newMessage(chat, text, keyboard)})
 }
 
 func (bot *Bot) SendInit(
@@ -118,25 +221,19 @@ func (bot *Bot) SendInit(
 }
 
 func (bot *Bot) SendAlert(a *analysis.Analysis, target float64) {
-	bot.SendMessage(fmt.Sprintf(
+	bot.sendMessageTo(signalsChatID, fmt.Sprintf(
 		"🔔 *%s* crossed %g\n\n"+
 			"    🖋 Price: *%g*\n"+
 			"    📊 Trend: _%s_ %s\n"+
 			"    💪 RSI: %.2f",
 		a.Asset.BaseAsset, target, a.Price, a.Trend, analysis.Emojis[a.Trend], a.RSI,
-	))
+	), nil)
 }
 
-func (bot *Bot) SendSignal(a *analysis.Analysis) {
-	text := fmt.Sprintf("⚡️ %s", a.Asset.BaseAsset)
-
-	if a.EMACross != "NA" {
-		text += fmt.Sprintf(" | _%s 5/9 EMA cross_ %s", a.EMACross, analysis.Emojis[a.EMACross])
-	}
-
-	if a.RSISignal != "NA" {
-		text += fmt.Sprintf(" | _RSI %s_ %s", a.RSISignal, analysis.Emojis[a.RSISignal])
-	}
+// SendSignal sends the signal found in the analysis, with a button to open a position on it when
+// positions are not opened automatically.
+func (bot *Bot) SendSignal(a *analysis.Analysis, withOpenButton bool) {
+	text := fmt.Sprintf("⚡️ %s", a.Asset.BaseAsset) + buildSignalsReport(a)
 
 	text += fmt.Sprintf("\n"+
 		"    🖋 Price: %g\n"+
@@ -146,34 +243,100 @@ func (bot *Bot) SendSignal(a *analysis.Analysis) {
 		a.Price, a.Trend, analysis.Emojis[a.Trend], a.RSI, a.Side, analysis.Emojis[a.Side],
 	)
 
-	bot.SendMessage(text)
+	var keyboard *tgbotapi.InlineKeyboardMarkup
+	if withOpenButton {
+		keyboard = buildSignalKeyboard(a)
+	}
+
+	// Signals are batched by processQueue, as many can be triggered at a candle's close.
+	bot.enqueue(outboundMessage{keyboard: keyboard, signal: text, symbol: a.Symbol})
 }
 
 func (bot *Bot) SendNewPosition(p *position.Position) {
-	bot.SendMessage(fmt.Sprintf("💡 Opened *%s* | %s %s\n\n"+
-		"    🖋 Entry @ %g with $%g\n"+
-		"    🧨 SL: %g (%g%%)\n"+
-		"    💎 TP: %g (%g%%)",
-		p.Symbol, p.Side, analysis.Emojis[p.Side],
-		p.EntryPrice, p.Size,
-		p.SL, position.SL*100,
-		p.TP, position.TP*100,
-	))
+	bot.enqueue(outboundMessage{
+		chattable: newMessage(positionsChatID, buildNewPositionReport(p), buildPositionKeyboard(p)),
+		position:  p,
+		symbol:    p.Symbol,
+	})
 }
 
 func (bot *Bot) SendClosedPosition(p *position.Position) {
 	pnlEmoji := GetPNLEmoji(p.PNL)
-	exitEmoji := map[string]string{"SL": "🧨", "TP": "💎"}[p.ExitSignal]
+	exitEmoji := map[string]string{"MANUAL": "✋", "PANIC": "🚨", "SL": "🧨", "TIME": "⏳", "TP": "💎"}[p.ExitSignal]
 
-	bot.SendMessage(fmt.Sprintf("%s Closed *%s* | %s\n\n"+
+	bot.sendMessageTo(positionsChatID, fmt.Sprintf("%s Closed *#%d %s* | %s\n\n"+
 		"    🖋 Exit @ %g with $%g\n"+
 		"    %s *%s* hit\n"+
+		"    🕰 Held for %s\n"+
+		"    💸 Fees: $%.2f | 🔁 Funding: $%.2f\n"+
 		"    💰 PNL: *$%.2f* (%.2f%%)",
-		pnlEmoji, p.Symbol, analysis.Emojis[p.Side],
+		pnlEmoji, p.ID, p.Symbol, analysis.Emojis[p.Side],
 		p.ExitPrice, p.Size,
 		exitEmoji, p.ExitSignal,
+		p.HoldingDuration(p.ExitTime).Round(time.Second),
+		p.Fees, p.Funding,
 		p.NetPNL, p.PNL,
-	))
+	), nil)
+}
+
+// SendPartialClose sends the portion of p closed by a take-profit level, along with what is left of p.
+func (bot *Bot) SendPartialClose(part *position.Position, p *position.Position) {
+	bot.sendMessageTo(positionsChatID, fmt.Sprintf("✂️ Took *%.0f%%* of *#%d %s* | %s\n\n"+
+		"    🖋 Exit @ %g with $%g\n"+
+		"    💎 *%s* hit\n"+
+		"    💸 Fees: $%.2f | 🔁 Funding: $%.2f\n"+
+		"    %s PNL: *$%.2f* (%.2f%%)\n"+
+		"    📦 Left: %g ($%.2f) | 🧨 SL: %g",
+		part.Quantity/part.InitialQuantity*100, part.ID, part.Symbol, analysis.Emojis[part.Side],
+		part.ExitPrice, part.Size,
+		part.ExitSignal,
+		part.Fees, part.Funding,
+		GetPNLEmoji(part.PNL), part.NetPNL, part.PNL,
+		p.Quantity, p.Size, p.SL,
+	), nil)
+}
+
+// SendPendingEntry sends the limit entry placed for p at its EntryPrice, as its attempt-th of attempts.
+func (bot *Bot) SendPendingEntry(p *position.Position, attempt int, attempts int) {
+	bot.sendMessageTo(positionsChatID, fmt.Sprintf(
+		"⏳ Placed a limit *%s* entry on *#%d %s* at %g (attempt %d/%d)",
+		p.Side, p.ID, p.Symbol, p.EntryPrice, attempt, attempts,
+	), nil)
+}
+
+func (bot *Bot) SendAbandonedEntry(p *position.Position, reason string) {
+	bot.sendMessageTo(positionsChatID, fmt.Sprintf(
+		"🚫 Abandoned the limit entry on *#%d %s*: %s", p.ID, p.Symbol, reason,
+	), nil)
+}
+
+// SendReport sends the account's performance over the summary's period, titled e.g. "DAILY", along
+// with its open positions.
+func (bot *Bot) SendReport(title string, summary account.Summary, acct *account.Account, symbolPrices map[string]float64) {
+	content := fmt.Sprintf(
+		"📊 *%s REPORT* 📊\n"+
+			"_%s → %s_\n\n"+
+			"    💡 Opened: %d | 📕 Closed: %d\n"+
+			"    🎯 Win rate: %.2f%% (%d/%d)\n"+
+			"    %s Net PNL: *$%.2f*\n"+
+			"    💸 Fees: $%.2f | 🔁 Funding: $%.2f\n",
+		title, summary.From.Format("Jan 2 15:04"), summary.To.Format("Jan 2 15:04"),
+		summary.Opened, summary.Closed,
+		summary.WinRate(), summary.Wins, summary.Closed,
+		GetPNLEmoji(summary.NetPNL), summary.NetPNL,
+		summary.Fees, summary.Funding,
+	)
+
+	if summary.Best != nil {
+		content += fmt.Sprintf(
+			"    🏆 Best: %s *$%.2f* (%.2f%%)\n"+
+				"    💀 Worst: %s *$%.2f* (%.2f%%)\n",
+			summary.Best.Symbol, summary.BestPNL, tradeReturn(summary.Best, summary.BestPNL),
+			summary.Worst.Symbol, summary.WorstPNL, tradeReturn(summary.Worst, summary.WorstPNL),
+		)
+	}
+
+	bot.SendMessage(content + "\n" + buildOpenPositionsReport(acct, symbolPrices))
 }
 
 // TODO: report account info (extract content from reportAccount)
@@ -187,30 +350,180 @@ func (bot *Bot) SendFinish(acct *account.Account, symbolPrices map[string]float6
 }
 
 func (bot *Bot) report(content string, update tgbotapi.Update) {
-	msg := tgbotapi.NewMessage(chatID, content)
-	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg := newMessage(update.Message.Chat.ID, content, nil)
 	msg.ReplyToMessageID = update.Message.MessageID // Reply to the previous message
 
-	if _, err := bot.Send(msg); err != nil {
-		bot.Error().Str("err", err.Error()).Msg("Could not send message")
+	bot.enqueue(outboundMessage{chattable: msg})
+}
+
+// openPosition parses the arguments of /open (SYMBOL SIDE [size] [sl] [tp]) and opens a manual position.
+func (bot *Bot) openPosition(engine Engine, update tgbotapi.Update) {
+	usage := "🤷 Usage: /open ETHUSDT BUY [size] [sl] [tp]"
+
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) < 2 || len(args) > 5 {
+		bot.report(usage, update)
+		return
+	}
+
+	// Optional size, SL, and TP default to 0 (i.e., computed by the engine).
+	var values [3]float64
+	for i, arg := range args[2:] {
+		value, err := strconv.ParseFloat(arg, 64)
+		if err != nil || value < 0 {
+			bot.report(usage, update)
+			return
+		}
+
+		values[i] = value
+	}
+
+	symbol, side := parseSymbol(args[0]), strings.ToUpper(args[1])
+
+	if _, err := engine.OpenPosition(symbol, side, values[0], values[1], values[2]); err != nil {
+		bot.report("🤷 "+err.Error(), update)
+	}
+}
+
+// haltTrading closes all open positions and stops opening new ones.
+func (bot *Bot) haltTrading(engine Engine) {
+	closedPositions := engine.Panic()
+
+	netPNL := 0.0
+	for _, p := range closedPositions {
+		netPNL += p.NetPNL
+	}
+
+	bot.SendMessage(fmt.Sprintf(
+		"🚨 *HALTED*: closed %d positions, not opening new ones until /resume\n\n"+
+			"    💰 PNL: *$%.2f*",
+		len(closedPositions), netPNL,
+	))
+}
+
+// toggleSignals parses the argument of /signals (on|off) and turns sending signals on or off.
+func (bot *Bot) toggleSignals(engine Engine, update tgbotapi.Update) {
+	switch strings.ToLower(strings.TrimSpace(update.Message.CommandArguments())) {
+	case "on":
+		engine.SetSignals(true)
+		bot.SendMessage("⚡️ Signals *on*")
+	case "off":
+		engine.SetSignals(false)
+		bot.SendMessage("🔕 Signals *off*")
+	default:
+		bot.report("🤷 Usage: /signals on|off", update)
+	}
+}
+
+// updateTargets parses the arguments of /sl and /tp (ID|SYMBOL PRICE) and /breakeven (ID|SYMBOL), and
+// updates the targets of the position.
+func (bot *Bot) updateTargets(acct *account.Account, engine Engine, update tgbotapi.Update) {
+	command := update.Message.Command()
+	usage := fmt.Sprintf("🤷 Usage: /%s 3|BTCUSDT", command)
+	if command != "breakeven" {
+		usage += " PRICE"
 	}
+
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) == 0 {
+		bot.report(usage, update)
+		return
+	}
+
+	p, err := parsePosition(acct, args[0])
+	if err != nil {
+		bot.report("🧘‍♂️ "+err.Error(), update)
+		return
+	}
+
+	sl, tp := p.SL, p.TP
+
+	if command == "breakeven" {
+		sl = p.EntryPrice
+	} else {
+		if len(args) != 2 {
+			bot.report(usage, update)
+			return
+		}
+
+		price, err := strconv.ParseFloat(args[1], 64)
+		if err != nil || price <= 0 {
+			bot.report(usage, update)
+			return
+		}
+
+		if command == "sl" {
+			sl = price
+		} else {
+			tp = price
+		}
+	}
+
+	p, err = engine.UpdateTargets(p.ID, sl, tp)
+	if err != nil {
+		bot.report("🤷 "+err.Error(), update)
+		return
+	}
+
+	bot.report(buildTargetsReport(p), update)
 }
 
-func (bot *Bot) reportAccount(acct *account.Account, symbolPrices map[string]float64, update tgbotapi.Update) {
-	totalTrades := len(acct.ClosedPositions)
+// confirmClose asks for confirmation before closing the position (ID or symbol) passed to /close.
+func (bot *Bot) confirmClose(acct *account.Account, symbolPrices map[string]float64, update tgbotapi.Update) {
+	arg := strings.TrimSpace(update.Message.CommandArguments())
+	if arg == "" {
+		bot.report("🤷 Usage: /close 3|BTCUSDT", update)
+		return
+	}
+
+	p, err := parsePosition(acct, arg)
+	if err != nil {
+		bot.report("🧘‍♂️ "+err.Error(), update)
+		return
+	}
+
+	bot.confirm(buildCloseConfirmation(p, symbolPrices[p.Symbol]), positionData(CLOSE, p), update.Message)
+}
+
+// confirmCloseAll asks for confirmation before closing all open positions.
+func (bot *Bot) confirmCloseAll(acct *account.Account, update tgbotapi.Update) {
+	openPositionsCount := len(acct.OpenPositions)
+	if openPositionsCount == 0 {
+		bot.report("🧘‍♂️ No open positions to close", update)
+		return
+	}
+
+	bot.confirm(
+		fmt.Sprintf("⚠️ Close all *%d* open positions?", openPositionsCount), CLOSE_ALL, update.Message,
+	)
+}
+
+func (bot *Bot) reportAccount(
+	acct *account.Account, symbolPrices map[string]float64, engine Engine, update tgbotapi.Update,
+) {
+	totalTrades := acct.Wins + acct.Loses // ClosedPositions also holds partial closes.
+	mode, sendsSignals := engine.Mode()
+	ledger := acct.Ledger(symbolPrices)
 
 	content := fmt.Sprintf(
-		"🪙 Allocated balance: *$%.2f*\n"+
+		"🚦 Mode: *%s* (signals: %t)\n"+
+			"🪙 Allocated balance: *$%.2f* (notional: $%.2f)\n"+
 			"💰 Available balance: $%.2f\n"+
 			"🖋 Initial balance: $%.2f\n"+
+			"🏦 Equity: *$%.2f* (%.2f%%)\n"+
 			"%s\n"+
 			"%s\n"+
+			"💸 Fees paid: $%.2f\n"+
+			"🔁 Funding paid: $%.2f\n"+
 			"💡 Open positions: %d\n"+
 			"🐸 Losing trades: *%d*/%d\n"+
 			"🎉 Winning trades: *%d*/%d",
-		acct.AllocatedBalance, acct.AvailableBalance, acct.InitialBalance,
+		mode, sendsSignals,
+		acct.AllocatedBalance, acct.Notional, acct.AvailableBalance, acct.InitialBalance,
+		ledger.Equity, ledger.Return,
 		buildNetPNLReport(acct),
 		buildUnrealPNLReport(acct, symbolPrices),
+		ledger.Fees, ledger.Funding,
 		len(acct.OpenPositions),
 		acct.Loses, totalTrades, acct.Wins, totalTrades,
 	)
@@ -218,33 +531,191 @@ func (bot *Bot) reportAccount(acct *account.Account, symbolPrices map[string]flo
 	bot.report(content, update)
 }
 
+// exportJournal replies with the trade journal's files as documents.
+func (bot *Bot) exportJournal(engine Engine, update tgbotapi.Update) {
+	for _, path := range engine.JournalFiles() {
+		document := tgbotapi.NewDocument(update.Message.Chat.ID, tgbotapi.FilePath(path))
+		document.ReplyToMessageID = update.Message.MessageID
+
+		bot.enqueue(outboundMessage{chattable: document})
+	}
+}
+
+// reportPrice replies with the last price of the symbol passed to /price.
+func (bot *Bot) reportPrice(engine Engine, update tgbotapi.Update) {
+	symbol := parseSymbol(update.Message.CommandArguments())
+	if symbol == "" {
+		bot.report("🤷 Usage: /price BTC", update)
+		return
+	}
+
+	price, err := engine.Price(symbol)
+	if err != nil {
+		bot.report("🤷 "+err.Error(), update)
+		return
+	}
+
+	bot.report(fmt.Sprintf("🖋 *%s*: %g", symbol, price), update)
+}
+
+// reportAnalysis replies with the analysis of the symbol passed to /ta.
+func (bot *Bot) reportAnalysis(engine Engine, update tgbotapi.Update) {
+	symbol := parseSymbol(update.Message.CommandArguments())
+	if symbol == "" {
+		bot.report("🤷 Usage: /ta BTC", update)
+		return
+	}
+
+	a, err := engine.Analyze(symbol)
+	if err != nil {
+		bot.report("🤷 "+err.Error(), update)
+		return
+	}
+
+	content := fmt.Sprintf("🔬 %s", a.Asset.BaseAsset) + buildSignalsReport(a)
+
+	content += fmt.Sprintf("\n"+
+		"    🖋 Price: %g\n"+
+		"    📊 Trend: _%s_ %s\n"+
+		"    💪 RSI: %.2f\n"+
+		"    〰️ EMA 5/9: %g / %g\n"+
+		"    〰️ EMA 50/100/200: %g / %g / %g",
+		a.Price, a.Trend, analysis.Emojis[a.Trend], a.RSI,
+		analysis.Round(a.EMA_005[2], a.Asset.PricePrecision), analysis.Round(a.EMA_009[2], a.Asset.PricePrecision),
+		analysis.Round(a.EMA_050, a.Asset.PricePrecision), analysis.Round(a.EMA_100, a.Asset.PricePrecision),
+		analysis.Round(a.EMA_200, a.Asset.PricePrecision),
+	)
+
+	if a.Side != analysis.NA {
+		content += fmt.Sprintf("\n\n    🔮 Side: *%s* %s", a.Side, analysis.Emojis[a.Side])
+	}
+
+	bot.report(content, update)
+}
+
 func (bot *Bot) reportNetPNL(acct *account.Account, update tgbotapi.Update) {
 	bot.report(buildNetPNLReport(acct), update)
 }
 
 func (bot *Bot) reportOpenPositions(acct *account.Account, symbolPrices map[string]float64, update tgbotapi.Update) {
+	bot.report(buildOpenPositionsReport(acct, symbolPrices), update)
+}
+
+func (bot *Bot) reportUnrealizedPNL(acct *account.Account, symbolPrices map[string]float64, update tgbotapi.Update) {
+	bot.report(buildUnrealPNLReport(acct, symbolPrices), update)
+}
+
+// buildSignalsReport returns the EMA cross and RSI signals found in the analysis (if any).
+func buildSignalsReport(a *analysis.Analysis) string {
+	text := ""
+
+	if a.EMACross != analysis.NA {
+		text += fmt.Sprintf(" | _%s 5/9 EMA cross_ %s", a.EMACross, analysis.Emojis[a.EMACross])
+	}
+
+	if a.RSISignal != analysis.NA {
+		text += fmt.Sprintf(" | _RSI %s_ %s", a.RSISignal, analysis.Emojis[a.RSISignal])
+	}
+
+	return text
+}
+
+func buildNewPositionReport(p *position.Position) string {
+	liquidation := "none"
+	if p.Liquidation > 0 {
+		liquidation = strconv.FormatFloat(p.Liquidation, 'f', -1, 64)
+	}
+
+	return fmt.Sprintf("💡 Opened *#%d %s* | %s %s\n\n"+
+		"    🖋 Entry @ %g with $%g\n"+
+		"    ⚖️ %dx %s ($%.2f margin) | 💀 Liq.: %s\n"+
+		"    🧨 SL: %g (%.2f%%)\n"+
+		"    💎 TP: %g (%.2f%%)\n"+
+		"    📡 Signal: _%s_",
+		p.ID, p.Symbol, p.Side, analysis.Emojis[p.Side],
+		p.EntryPrice, p.Size,
+		p.Leverage, strings.ToLower(p.MarginType), p.Margin, liquidation,
+		p.SL, math.Abs(p.SL-p.EntryPrice)/p.EntryPrice*100,
+		p.TP, math.Abs(p.TP-p.EntryPrice)/p.EntryPrice*100,
+		p.EntrySignal,
+	) + buildTakeProfitsReport(p)
+}
+
+// buildTakeProfitsReport returns the pending take-profit levels of the position and its trailing SL
+// (empty if it has none).
+func buildTakeProfitsReport(p *position.Position) string {
+	var levels []string
+
+	for _, level := range p.TakeProfits[p.TakeProfitsHit:] {
+		levels = append(levels, fmt.Sprintf("%.0f%% @ %g", level.Fraction*100, level.Price))
+	}
+
+	content := ""
+
+	if len(levels) >= 1 {
+		content += "\n    ✂️ Take profits: " + strings.Join(levels, ", ")
+	}
+
+	if p.Trailing > 0 {
+		content += fmt.Sprintf("\n    🪜 Trailing SL: %g%%", p.Trailing*100)
+	}
+
+	return content
+}
+
+func buildCloseConfirmation(p *position.Position, price float64) string {
+	pnl := p.UnrealizedPNL(price)
+
+	return fmt.Sprintf(
+		"⚠️ Close *#%d %s* | %s %s?\n\n"+
+			"    %s uPNL: *$%.2f* (%.2f%%)",
+		p.ID, p.Symbol, p.Side, analysis.Emojis[p.Side],
+		GetPNLEmoji(pnl), pnl, pnl/p.Size*100,
+	)
+}
+
+func buildClosedPositionReport(p *position.Position) string {
+	return fmt.Sprintf(
+		"%s Closed *#%d %s* @ %g\n\n    💰 PNL: *$%.2f* (%.2f%%)",
+		GetPNLEmoji(p.PNL), p.ID, p.Symbol, p.ExitPrice, p.NetPNL, p.PNL,
+	)
+}
+
+func buildTargetsReport(p *position.Position) string {
+	return fmt.Sprintf(
+		"🎯 Updated *#%d %s* | %s %s\n\n"+
+			"    🧨 SL: %g (%.2f%%)\n"+
+			"    💎 TP: %g (%.2f%%)",
+		p.ID, p.Symbol, p.Side, analysis.Emojis[p.Side],
+		p.SL, math.Abs(p.SL-p.EntryPrice)/p.EntryPrice*100,
+		p.TP, math.Abs(p.TP-p.EntryPrice)/p.EntryPrice*100,
+	)
+}
+
+func buildOpenPositionsReport(acct *account.Account, symbolPrices map[string]float64) string {
 	content := "🧘‍♂️ No open positions to report"
-	unrealizedPNLs := acct.CalculateOpenPositionsPNLs(symbolPrices)
-	openPositionsCount := len(unrealizedPNLs)
+	openPositionsCount := len(acct.OpenPositions)
 
 	if openPositionsCount >= 1 {
 		content = fmt.Sprintf("📄 Got %d open positions\n\n", openPositionsCount)
 
-		for symbol, pnlPair := range unrealizedPNLs {
-			unrealizedPNL, rawPNL := pnlPair[0], pnlPair[1]
+		for _, p := range acct.OpenPositions {
+			pnl := p.UnrealizedPNL(symbolPrices[p.Symbol])
+
+			closePending := ""
+			if p.ClosePending != "" {
+				closePending = " | ⚠️ close pending"
+			}
 
 			content += fmt.Sprintf(
-				"    %s %s: *$%.2f* (%.2f%%)\n",
-				GetPNLEmoji(unrealizedPNL), symbol, unrealizedPNL, rawPNL,
+				"    %s #%d %s %s: *$%.2f* (%.2f%%) | 🕰 %s%s\n",
+				GetPNLEmoji(pnl), p.ID, p.Symbol, analysis.Emojis[p.Side], pnl, pnl/p.Size*100,
+				p.HoldingDuration(time.Now()).Round(time.Minute), closePending,
 			)
 		}
 	}
 
-	bot.report(content, update)
-}
-
-func (bot *Bot) reportUnrealizedPNL(acct *account.Account, symbolPrices map[string]float64, update tgbotapi.Update) {
-	bot.report(buildUnrealPNLReport(acct, symbolPrices), update)
+	return content
 }
 
 func buildNetPNLReport(acct *account.Account) string {
@@ -263,6 +734,66 @@ func buildUnrealPNLReport(acct *account.Account, symbolPrices map[string]float64
 	)
 }
 
+// findOpenPosition returns the account's oldest open position for symbol, or nil if there is none.
+func findOpenPosition(acct *account.Account, symbol string) *position.Position {
+	if openPositions := acct.OpenPositionsOf(symbol); len(openPositions) >= 1 {
+		return openPositions[0]
+	}
+
+	return nil
+}
+
+// parsePosition resolves a command argument into an open position: either its ID (e.g., "3", "#3") or
+// its symbol (e.g., "btc"), as long as the symbol has a single open position.
+func parsePosition(acct *account.Account, arg string) (*position.Position, error) {
+	if id, err := strconv.Atoi(strings.TrimPrefix(arg, "#")); err == nil {
+		if p := acct.FindOpenPosition(id); p != nil {
+			return p, nil
+		}
+
+		return nil, fmt.Errorf("no open position *#%d*", id)
+	}
+
+	symbol := parseSymbol(arg)
+	openPositions := acct.OpenPositionsOf(symbol)
+
+	switch len(openPositions) {
+	case 0:
+		return nil, fmt.Errorf("no open position for *%s*", symbol)
+	case 1:
+		return openPositions[0], nil
+	}
+
+	ids := make([]string, len(openPositions))
+	for i, p := range openPositions {
+		ids[i] = fmt.Sprintf("#%d", p.ID)
+	}
+
+	return nil, fmt.Errorf("*%s* has %d open positions: pass one of their IDs (%s)",
+		symbol, len(openPositions), strings.Join(ids, ", "),
+	)
+}
+
+// parseSymbol normalises a command argument (e.g., "btc", "BTCUSDT") into a USDT symbol.
+func parseSymbol(arg string) string {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	symbol := strings.ToUpper(fields[0])
+	if !strings.HasSuffix(symbol, "USDT") {
+		symbol += "USDT"
+	}
+
+	return symbol
+}
+
+// tradeReturn returns the trade PNL of the closed position p as a percentage of its initial size.
+func tradeReturn(p *position.Position, tradePNL float64) float64 {
+	return tradePNL / (p.InitialQuantity * p.EntryPrice) * 100
+}
+
 // TODO: turn function into map (keys being True and False)
 func GetPNLEmoji(pnl float64) string {
 	if pnl >= 0 {
@@ -2,17 +2,21 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"hermes/account"
 	"hermes/analysis"
-	"hermes/exchange"
-	"hermes/telegram"
+	"hermes/entry"
+	"hermes/position"
+	"hermes/risk"
+	"hermes/sizing"
 
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog"
@@ -41,14 +45,61 @@ func InitLogging() zerolog.Logger {
 	return zerolog.New(io.MultiWriter(consoleOutput, logFile)).With().Timestamp().Logger()
 }
 
-// ParseFlags parses the CLI flags, validates the interval passed, and returns pointers to their values.
-func ParseFlags(log *zerolog.Logger) (float64, bool, string, int, bool, bool, bool) {
+// Flags holds the values of the CLI flags.
+type Flags struct {
+	Balance        float64                 // Initial balance to simulate trading.
+	Dev            bool                    // Whether to use the development Telegram bot.
+	Entry          entry.Settings          // How new positions are entered (market or limit orders).
+	Fees           position.FeeSchedule    // Commission rates of the account's fee tier.
+	FundingRates   string                  // Path of a recorded series of funding rates (empty to fetch them from Binance).
+	Hedge          bool                    // Whether to allow a long and a short position on the same symbol.
+	Interval       string                  // Interval to perform TA.
+	Margin         position.MarginSettings // Default leverage and margin type.
+	MaxEntries     int                     // Maximum positions per symbol and side (i.e., scale-in entries).
+	MaxHolding     time.Duration           // Time after which positions are closed (0 to disable).
+	MaxPositions   int                     // Maximum positions to open.
+	ReportDay      time.Weekday            // Day of the weekly report.
+	ReportTime     string                  // Time of the daily report ("HH:MM", empty to disable reports).
+	Risk           risk.Limits             // Thresholds of the risk breakers.
+	TrackPositions bool                    // Whether to open positions when signals are triggered.
+	IsReal         bool                    // Whether to open real trades.
+	SendSignals    bool                    // Whether to send signals.
+	Sizing         sizing.Sizing           // How the size of new positions is computed.
+	TakeProfits    []position.TakeProfit   // Partial take-profit levels of new positions, nearest first.
+	Trailing       float64                 // Distance of the SL trailing the rest once TakeProfits are hit (fraction).
+}
+
+// ParseFlags parses the CLI flags, validates the interval and reports' day and time passed, and
+// returns their values.
+func ParseFlags(log *zerolog.Logger) Flags {
 	balance := flag.Float64("balance", 1000, "initial balance to simulate trading (ignored when trade=true)")
 	dev := flag.Bool("dev", true, "send alerts to development bot (DEV_TELEGRAM_* in .env)")
+	entryMode := flag.String("entry", entry.MARKET, "order type to enter positions with: market, limit (post-only)")
+	feeTier := flag.Int("fee-tier", 0, "Binance USD-M fee tier (VIP level) to charge commissions at: 0-9")
+	fundingRates := flag.String("funding-rates", "", "JSON file of recorded funding rates (as returned by /fapi/v1/fundingRate)")
+	hedge := flag.Bool("hedge", false, "hedge mode: allow a long and a short position on the same symbol")
 	interval := flag.String("interval", "", "interval to perform TA: 1m, 3m, 5m, 15m, 30m, 1h, 2h, 4h, 12h, 1d")
+	leverage := flag.Int("leverage", 1, "default leverage to open positions with: 1-125 (see margin.example.json)")
+	limitFallback := flag.String("limit-fallback", entry.ABANDON, "what to do with limit entries left unfilled: abandon, market")
+	limitOffset := flag.Float64("limit-offset", 0, "place limit entries this % below (BUY) or above (SELL) the signal price")
+	limitReprices := flag.Int("limit-reprices", 2, "times unfilled limit entries are re-priced before the fallback")
+	limitTimeout := flag.Duration("limit-timeout", 30*time.Second, "time to wait for a limit entry to be filled before re-pricing it")
+	marginType := flag.String("margin-type", position.ISOLATED, "default margin type: ISOLATED, CROSSED")
+	maxEntries := flag.Int("max-entries", 1, "maximum positions per symbol and side (more than 1 to scale in)")
+	maxHolding := flag.Duration("max-holding", 0, "close positions held for longer than this (e.g., 12h; 0 to disable)")
+	maxDailyLoss := flag.Float64("max-daily-loss", 0, "stop opening positions for the day after losing this % of the balance (0 to disable)")
+	maxDrawdown := flag.Float64("max-drawdown", 0, "stop opening positions after a drawdown of this % from the equity peak (0 to disable)")
+	maxExposure := flag.Float64("max-exposure", 0, "maximum net exposure in one direction, as a % of the equity (0 to disable)")
+	maxLosses := flag.Int("max-losses", 0, "stop opening positions for the day after this many consecutive losses (0 to disable)")
 	maxPositions := flag.Int("max-positions", 4, "maximum positions to open")
+	reportDay := flag.String("report-day", "monday", "day of the week to send the weekly report on")
+	reportTime := flag.String("report-time", "00:00", "local time (HH:MM) to send the daily report at (empty to disable)")
 	trackPositions := flag.Bool("positions", true, "open positions when signals are triggered (simulated by default)")
 	isReal := flag.Bool("real", false, "open a real trade for every position on Binance USD-M")
+	sizingMode := flag.String("sizing", sizing.FRACTION, "sizing mode of new positions: fraction, fixed, risk, atr")
+	sizingValue := flag.Float64("sizing-value", 0, "balance fraction (fraction, risk, atr) or USDT (fixed) to size positions with (fraction defaults to 1/max-positions)")
+	takeProfits := flag.String("take-profits", "", "partial take-profits as % of the position:% from the entry (e.g., 50:5,30:10)")
+	trailing := flag.Float64("trailing", 0, "trail the SL this % away from the price once the take-profits are hit (0 to disable)")
 	sendSignals := flag.Bool("signals", false, "send alerts on Telegram when a signal is triggered")
 
 	flag.Parse()
@@ -67,7 +118,103 @@ func ParseFlags(log *zerolog.Logger) (float64, bool, string, int, bool, bool, bo
 		os.Exit(2)
 	}
 
-	return *balance, *dev, *interval, *maxPositions, *trackPositions, *isReal, *sendSignals
+	if _, err := time.Parse("15:04", *reportTime); *reportTime != "" && err != nil {
+		log.Error().Msg("Please specify a valid report time (HH:MM)")
+		os.Exit(2)
+	}
+
+	if *maxEntries < 1 {
+		log.Error().Msg("Please specify at least 1 entry per symbol and side")
+		os.Exit(2)
+	}
+
+	if *feeTier < 0 || *feeTier >= len(position.FEE_TIERS) {
+		log.Error().Msg("Please specify a valid fee tier (0-9)")
+		os.Exit(2)
+	}
+
+	margin := position.MarginSettings{Leverage: *leverage, MarginType: strings.ToUpper(*marginType)}
+	if err := ValidateMarginSettings(margin); err != nil {
+		log.Error().Str("err", err.Error()).Msg("Please specify a valid leverage and margin type")
+		os.Exit(2)
+	}
+
+	positionSizing := sizing.Sizing{Mode: strings.ToLower(*sizingMode), Value: *sizingValue}
+	if positionSizing.Mode == sizing.FRACTION && positionSizing.Value == 0 {
+		positionSizing.Value = 1 / float64(*maxPositions)
+	}
+
+	if err := positionSizing.Validate(); err != nil {
+		log.Error().Str("err", err.Error()).Msg("Please specify a valid sizing mode and value")
+		os.Exit(2)
+	}
+
+	entrySettings := entry.Settings{
+		Fallback: strings.ToLower(*limitFallback),
+		Mode:     strings.ToLower(*entryMode),
+		Offset:   *limitOffset / 100,
+		Reprices: *limitReprices,
+		Timeout:  *limitTimeout,
+	}
+
+	if err := entrySettings.Validate(); err != nil {
+		log.Error().Str("err", err.Error()).Msg("Please specify a valid entry mode and limit settings")
+		os.Exit(2)
+	}
+
+	takeProfitLevels, err := ParseTakeProfits(*takeProfits)
+	if err != nil {
+		log.Error().Str("err", err.Error()).Msg("Please specify valid take-profits (e.g., 50:5,30:10)")
+		os.Exit(2)
+	}
+
+	if *trailing < 0 || *trailing >= 100 {
+		log.Error().Msg("Please specify a valid trailing SL (0-100%)")
+		os.Exit(2)
+	}
+
+	weekday, weekdayIsValid := time.Sunday, false
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(*reportDay, day.String()) {
+			weekday, weekdayIsValid = day, true
+			break
+		}
+	}
+
+	if !weekdayIsValid {
+		log.Error().Msg("Please specify a valid report day (e.g., monday)")
+		os.Exit(2)
+	}
+
+	riskLimits := risk.Limits{
+		MaxDailyLoss: *maxDailyLoss / 100,
+		MaxDrawdown:  *maxDrawdown / 100,
+		MaxExposure:  *maxExposure / 100,
+		MaxLosses:    *maxLosses,
+	}
+
+	return Flags{
+		Balance:        *balance,
+		Dev:            *dev,
+		Entry:          entrySettings,
+		Fees:           position.FEE_TIERS[*feeTier],
+		FundingRates:   *fundingRates,
+		Hedge:          *hedge,
+		Interval:       *interval,
+		Margin:         margin,
+		MaxEntries:     *maxEntries,
+		MaxHolding:     *maxHolding,
+		MaxPositions:   *maxPositions,
+		ReportDay:      weekday,
+		ReportTime:     *reportTime,
+		Risk:           riskLimits,
+		TrackPositions: *trackPositions,
+		IsReal:         *isReal,
+		SendSignals:    *sendSignals,
+		Sizing:         positionSizing,
+		TakeProfits:    takeProfitLevels,
+		Trailing:       *trailing / 100,
+	}
 }
 
 // LoadAlerts parses the alerts.json file into a struct of type Alert.
@@ -95,18 +242,140 @@ func LoadAlerts(log *zerolog.Logger, interval string, validSymbols map[string]st
 	return alerts, alertSymbols
 }
 
-// LoadEnvFile makes the variable in the .env file available via os.GetEnv() using godotenv.
+// LoadFundingRates parses a JSON file of funding rates, as returned by Binance's /fapi/v1/fundingRate, into
+// a position.FundingRates series.
+func LoadFundingRates(log *zerolog.Logger, path string) position.FundingRates {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+
+	records := []struct {
+		Symbol      string `json:"symbol"`
+		FundingRate string `json:"fundingRate"`
+		FundingTime int64  `json:"fundingTime"`
+	}{}
+	if err := json.Unmarshal(dat, &records); err != nil {
+		log.Fatal().Str("err", err.Error()).Msg("Crashed parsing funding rates")
+	}
+
+	fundingRates := make(position.FundingRates)
+	for _, record := range records {
+		rate, err := strconv.ParseFloat(record.FundingRate, 64)
+		if err != nil {
+			log.Fatal().Str("fundingRate", record.FundingRate).Msg("Crashed parsing funding rates")
+		}
+
+		if fundingRates[record.Symbol] == nil {
+			fundingRates[record.Symbol] = make(map[int64]float64)
+		}
+
+		// Binance settles funding a few milliseconds after the funding time: round them down.
+		t := time.UnixMilli(record.FundingTime).Truncate(time.Second)
+		fundingRates[record.Symbol][t.UnixMilli()] = rate
+	}
+
+	return fundingRates
+}
+
+// LoadMarginSettings parses the optional margin.json file into the leverage and margin type of each symbol
+// listed, filling the fields left out with defaults.
+func LoadMarginSettings(log *zerolog.Logger, defaults position.MarginSettings) map[string]position.MarginSettings {
+	symbolMargins := make(map[string]position.MarginSettings)
+
+	dat, err := os.ReadFile("./margin.json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return symbolMargins
+	} else if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+
+	if err := json.Unmarshal(dat, &symbolMargins); err != nil {
+		log.Fatal().Str("err", err.Error()).Msg("Crashed parsing margin.json")
+	}
+
+	for symbol, margin := range symbolMargins {
+		if margin.Leverage == 0 {
+			margin.Leverage = defaults.Leverage
+		}
+
+		if margin.MarginType == "" {
+			margin.MarginType = defaults.MarginType
+		}
+
+		margin.MarginType = strings.ToUpper(margin.MarginType)
+		if err := ValidateMarginSettings(margin); err != nil {
+			log.Fatal().Str("err", err.Error()).Str("symbol", symbol).Msg("Crashed parsing margin.json")
+		}
+
+		symbolMargins[symbol] = margin
+	}
+
+	return symbolMargins
+}
+
+// ValidateMarginSettings returns an error if the leverage or margin type are not supported by Binance USD-M.
+func ValidateMarginSettings(margin position.MarginSettings) error {
+	if margin.Leverage < 1 || margin.Leverage > 125 {
+		return fmt.Errorf("leverage should be between 1 and 125, got %d", margin.Leverage)
+	}
+
+	if margin.MarginType != position.ISOLATED && margin.MarginType != position.CROSSED {
+		return fmt.Errorf("margin type should be %s or %s, got %s", position.ISOLATED, position.CROSSED, margin.MarginType)
+	}
+
+	return nil
+}
+
+// ParseTakeProfits parses take-profit levels formatted as "<% of the position>:<% from the entry>", comma
+// separated and nearest first (e.g., "50:5,30:10"). Their percentages of the position should add up to
+// 100% at most.
+func ParseTakeProfits(value string) ([]position.TakeProfit, error) {
+	var levels []position.TakeProfit
+
+	if strings.TrimSpace(value) == "" {
+		return levels, nil
+	}
+
+	total := 0.0
+
+	for _, rawLevel := range strings.Split(value, ",") {
+		var percentage, distance float64
+		if _, err := fmt.Sscanf(strings.TrimSpace(rawLevel), "%g:%g", &percentage, &distance); err != nil {
+			return nil, fmt.Errorf("could not parse take-profit %q", rawLevel)
+		}
+
+		switch {
+		case percentage <= 0 || distance <= 0:
+			return nil, fmt.Errorf("take-profit %q should have positive percentages", rawLevel)
+		case len(levels) >= 1 && distance/100 <= levels[len(levels)-1].Distance:
+			return nil, fmt.Errorf("take-profit %q should be further than the previous one", rawLevel)
+		}
+
+		if total += percentage; total > 100 {
+			return nil, fmt.Errorf("take-profits close %g%% of the position, more than 100%%", total)
+		}
+
+		levels = append(levels, position.TakeProfit{Distance: distance / 100, Fraction: percentage / 100})
+	}
+
+	return levels, nil
+}
+
+// LoadEnvFile makes the variable in the .env file available via os.GetEnv() using godotenv. A missing
+// .env file is not an error: variables may be set in the environment (e.g., when running headless).
 func LoadEnvFile(log *zerolog.Logger) {
 	err := godotenv.Load()
-	if err != nil {
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Warn().Msg("No .env file found: using the environment's variables")
+	} else if err != nil {
 		log.Fatal().Str("err", err.Error()).Msg("Crashed loading .env file")
 	}
 }
 
-func HandleCTRLC(
-	acct *account.Account, bot *telegram.Bot, c chan os.Signal, excg *exchange.Exchange,
-	isReal bool, log *zerolog.Logger, symbolPrices map[string]float64, usesTelegramBot bool,
-) {
+// HandleCTRLC asks for confirmation on CTRL-C, and calls shutdown (e.g., to cancel pending orders and close
+// the open positions) before exiting.
+func HandleCTRLC(c chan os.Signal, log *zerolog.Logger, shutdown func()) {
 	for sig := range c {
 		var wantsToExit string
 
@@ -118,23 +387,7 @@ func HandleCTRLC(
 		if wantsToExit == "Y" || wantsToExit == "YES" {
 			log.Warn().Str("sig", sig.String()).Msg("Received CTRL-C. Exiting...")
 
-			if isReal {
-				excg.CloseAllPositions(acct.OpenPositions)
-			}
-
-			if usesTelegramBot {
-				bot.SendFinish(acct, symbolPrices)
-			}
-
-			log.Info().
-				Float64("AllocatedBalance", acct.AllocatedBalance).
-				Float64("AvailableBalance", acct.AvailableBalance).
-				Float64("TotalBalance", acct.TotalBalance).
-				Float64("NetPNL", acct.NetPNL).
-				Float64("PNL", acct.PNL).
-				Int("Loses", acct.Loses).
-				Int("Wins", acct.Wins).
-				Msg("📄")
+			shutdown()
 
 			close(c)
 			os.Exit(1)